@@ -0,0 +1,305 @@
+package protosql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// aggregation queries built from the same filter, joins and grouping as repoQ
+
+var errAggregateQuery = errors.New("aggregation of union, global search or custom select query is not supported")
+
+// Having adds HAVING clause to grouped query, lval of expressions can be aggregates (e.g. "count(*)")
+func (q *repoQ) Having(f *Filter) *repoQ {
+	q.having = f
+	return q
+}
+
+// Count returns number of rows (groups for grouped query) ignoring sorting and pagination
+func (q *repoQ) Count() (int64, error) {
+	sq, args, err := q.innerQ()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	err = q.scanRow(fmt.Sprintf("SELECT count(*) FROM (%s) AS count_q", sq), args, &n)
+	return n, err
+}
+
+// Exists checks that query returns at least one row
+func (q *repoQ) Exists() (bool, error) {
+	sq, args, err := q.innerQ()
+	if err != nil {
+		return false, err
+	}
+
+	var ok bool
+	err = q.scanRow(fmt.Sprintf("SELECT EXISTS (%s)", sq), args, &ok)
+	return ok, err
+}
+
+// innerQ builds query without sorting, pagination and lock
+func (q *repoQ) innerQ() (string, []interface{}, error) {
+	if len(q.unionQueries) > 0 {
+		return q.unionQ()
+	}
+	if q.globalSearchTerm != "" {
+		return "", nil, errAggregateQuery
+	}
+
+	inner := *q
+	inner.sorting, inner.lock, inner.preloads = nil, false, nil
+
+	return inner.buildQ(1, "", nil)
+}
+
+func (q *repoQ) scanRow(req string, args []interface{}, dest ...interface{}) error {
+	defer addMetricSince("select", req, time.Now())
+
+	q.r.logger.Debugf("QUERY: %s, ARGS: %+v", req, args)
+
+	rows, err := q.r.b.query(q.ctx, req, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+
+	return rows.Err()
+}
+
+// Aggregation is aggregate select expression with alias (column name in result)
+type Aggregation struct {
+	expr  string
+	alias string
+}
+
+// Agg returns custom aggregate expression, e.g. Agg("count(DISTINCT owner_id)", "owners")
+func Agg(expr, alias string) Aggregation {
+	return Aggregation{expr: expr, alias: alias}
+}
+
+// CountOf counts non NULL values of column ("*" counts rows)
+func CountOf(column string) Aggregation {
+	return newAggregation("count", "count(%s)", column)
+}
+
+// Sum is 0 if there are no rows
+func Sum(column string) Aggregation {
+	return newAggregation("sum", "COALESCE(sum(%s), 0)", column)
+}
+
+// Avg is NULL if there are no rows
+func Avg(column string) Aggregation {
+	return newAggregation("avg", "avg(%s)", column)
+}
+
+// Min is NULL if there are no rows
+func Min(column string) Aggregation {
+	return newAggregation("min", "min(%s)", column)
+}
+
+// Max is NULL if there are no rows
+func Max(column string) Aggregation {
+	return newAggregation("max", "max(%s)", column)
+}
+
+// alias is fn_column (without table prefix), e.g. sum_count
+func newAggregation(fn, format, column string) Aggregation {
+	alias := fn
+	if column != "*" {
+		alias += "_" + column[strings.LastIndex(column, ".")+1:]
+	}
+	return Aggregation{expr: fmt.Sprintf(format, column), alias: alias}
+}
+
+// As sets alias of aggregate
+func (a Aggregation) As(alias string) Aggregation {
+	a.alias = alias
+	return a
+}
+
+func (a Aggregation) String() string {
+	return fmt.Sprintf("%s AS %s", a.expr, a.alias)
+}
+
+type aggQ struct {
+	q    *repoQ
+	aggs []Aggregation
+}
+
+// Aggregate selects GroupBy columns followed by aggregates instead of model columns.
+// Sorting and pagination are applied only to grouped query.
+func (q *repoQ) Aggregate(aggs ...Aggregation) *aggQ {
+	return &aggQ{q: q, aggs: aggs}
+}
+
+func (a *aggQ) build() (string, []interface{}, error) {
+	q := *a.q
+	if len(q.unionQueries) > 0 || q.globalSearchTerm != "" || q.query != "" {
+		return "", nil, errAggregateQuery
+	}
+
+	q.selectExprs = append([]string{}, q.groupBy...)
+	for _, agg := range a.aggs {
+		q.selectExprs = append(q.selectExprs, agg.String())
+	}
+	q.lock = false
+	if len(q.groupBy) == 0 {
+		q.sorting, q.pager = nil, nil
+	}
+
+	return q.buildQ(1, "", q.pager)
+}
+
+// Scan scans first row into dest, one pointer per group column and aggregate
+// (e.g. *int64, *float64, or **float64 for aggregates which can be NULL)
+func (a *aggQ) Scan(dest ...interface{}) error {
+	req, args, err := a.build()
+	if err != nil {
+		return err
+	}
+
+	return a.q.scanRow(req, args, dest...)
+}
+
+// Fetch scans rows into dest: pointer to slice of structs, pointers to structs
+// or map[string]interface{}. Columns are matched with struct fields by `db` tag
+// or by field name ignoring case and underscores.
+func (a *aggQ) Fetch(dest interface{}) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("ptr to slice should be passed for Fetch()")
+	}
+	lst := dv.Elem()
+
+	req, args, err := a.build()
+	if err != nil {
+		return err
+	}
+
+	defer addMetricSince("select", req, time.Now())
+
+	a.q.r.logger.Debugf("QUERY: %s, ARGS: %+v", req, args)
+
+	rows, err := a.q.r.b.query(a.q.ctx, req, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		item, err := scanAggRow(rows, cols, lst.Type().Elem())
+		if err != nil {
+			return err
+		}
+		lst.Set(reflect.Append(lst, item))
+	}
+
+	return rows.Err()
+}
+
+func scanAggRow(rows rows, cols []string, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Map {
+		if t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.Interface {
+			return reflect.Value{}, fmt.Errorf("unsupported map type %s", t)
+		}
+
+		vals := make([]interface{}, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range vals {
+			dest[i] = &vals[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return reflect.Value{}, err
+		}
+
+		m := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			m[col] = aggValue(vals[i])
+		}
+		return reflect.ValueOf(m), nil
+	}
+
+	st := t
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("unsupported result type %s", t)
+	}
+
+	item := reflect.New(st)
+	dest := make([]interface{}, len(cols))
+	for i, col := range cols {
+		idx, ok := structFieldByColumn(st, col)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("no field for column %s in %s", col, st)
+		}
+		dest[i] = item.Elem().Field(idx).Addr().Interface()
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return reflect.Value{}, err
+	}
+
+	if t.Kind() == reflect.Ptr {
+		return item, nil
+	}
+	return item.Elem(), nil
+}
+
+func structFieldByColumn(t reflect.Type, col string) (int, bool) {
+	norm := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if tag, ok := f.Tag.Lookup("db"); ok {
+			if tag == col {
+				return i, true
+			}
+			continue
+		}
+		if norm(f.Name) == norm(col) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// aggValue converts driver specific values (numeric as text or pgtype.Numeric)
+func aggValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case driver.Valuer:
+		if dv, err := val.Value(); err == nil {
+			return dv
+		}
+	}
+	return v
+}
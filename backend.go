@@ -0,0 +1,77 @@
+package protosql
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// backend is a database driver used by Repo.
+// There are two implementations: database/sql + lib/pq (NewRepo)
+// and native pgx v5 pool (NewPgxRepo).
+type backend interface {
+	Codec
+
+	exec(ctx context.Context, q string, args ...interface{}) (int64, error)
+	query(ctx context.Context, q string, args ...interface{}) (rows, error)
+	execBatch(ctx context.Context, q string, params [][]interface{}) error
+
+	inTx(ctx context.Context) (bool, error) // ErrForeignTx for transaction of another backend
+	begin(ctx context.Context) (context.Context, dbTx, error)
+}
+
+// Codec converts proto field values into driver specific query arguments
+// and scan destinations. It is used by code generated with protoc-gen-protosql.
+type Codec interface {
+	ArrayArg(v interface{}) interface{}
+	DurationArg(d time.Duration) interface{}
+
+	ArrayDest(dest interface{}) interface{}
+	DurationDest(d **durationpb.Duration) interface{}
+}
+
+// filterArgs converts slice arguments of filters by Codec.ArrayArg
+// (database/sql does not accept plain slices without pq.Array).
+// Arguments passed to Exec/ExecBatch by caller are not converted.
+func filterArgs(c Codec, args []interface{}) []interface{} {
+	var ret []interface{}
+	for i, a := range args {
+		if _, ok := a.(driver.Valuer); ok {
+			continue
+		}
+		if rv := reflect.ValueOf(a); rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+			continue
+		}
+
+		if ret == nil {
+			ret = append([]interface{}{}, args...)
+		}
+		ret[i] = c.ArrayArg(a)
+	}
+
+	if ret == nil {
+		return args
+	}
+
+	return ret
+}
+
+type dbTx interface {
+	commit() error
+	rollback() error
+}
+
+type rows interface {
+	scanner
+
+	Next() bool
+	Columns() ([]string, error)
+	Err() error
+	Close() error
+}
+
+// context key for opened transaction
+const txCtxKey = "_dbtx_"
@@ -0,0 +1,185 @@
+package protosql
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type pgxBackend struct {
+	pool *pgxpool.Pool
+}
+
+type pgxExec interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+func (b *pgxBackend) getDB(ctx context.Context) (pgxExec, error) {
+	v := ctx.Value(txCtxKey)
+	if v == nil {
+		return b.pool, nil
+	}
+
+	tx, ok := v.(pgx.Tx)
+	if !ok {
+		return nil, ErrForeignTx
+	}
+
+	return tx, nil
+}
+
+func (b *pgxBackend) exec(ctx context.Context, q string, args ...interface{}) (int64, error) {
+	db, err := b.getDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := db.Exec(ctx, q, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (b *pgxBackend) query(ctx context.Context, q string, args ...interface{}) (rows, error) {
+	db, err := b.getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgxRows{r}, nil
+}
+
+// execBatch sends all rows in a single pgx batch (pipelined round trip)
+func (b *pgxBackend) execBatch(ctx context.Context, q string, params [][]interface{}) error {
+	db, err := b.getDB(ctx)
+	if err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, row := range params {
+		batch.Queue(q, row...)
+	}
+
+	res := db.SendBatch(ctx, batch)
+	for range params {
+		if _, err := res.Exec(); err != nil {
+			res.Close()
+			return err
+		}
+	}
+
+	return res.Close()
+}
+
+func (b *pgxBackend) inTx(ctx context.Context) (bool, error) {
+	_, err := b.getDB(ctx)
+	return ctx.Value(txCtxKey) != nil && err == nil, err
+}
+
+func (b *pgxBackend) begin(ctx context.Context) (context.Context, dbTx, error) {
+	tx, err := b.pool.Begin(ctx)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	return context.WithValue(ctx, txCtxKey, tx), pgxTx{ctx: ctx, tx: tx}, nil
+}
+
+// pgx encodes and decodes slices natively, so pq.Array wrappers are not needed
+func (b *pgxBackend) ArrayArg(v interface{}) interface{} {
+	return v
+}
+
+func (b *pgxBackend) DurationArg(d time.Duration) interface{} {
+	return pgxDuration{d}
+}
+
+func (b *pgxBackend) ArrayDest(dest interface{}) interface{} {
+	return dest
+}
+
+func (b *pgxBackend) DurationDest(d **durationpb.Duration) interface{} {
+	return &pgxDurationScanner{d}
+}
+
+type pgxTx struct {
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func (t pgxTx) commit() error   { return t.tx.Commit(t.ctx) }
+func (t pgxTx) rollback() error { return t.tx.Rollback(t.ctx) }
+
+type pgxRows struct {
+	pgx.Rows
+}
+
+func (r pgxRows) Columns() ([]string, error) {
+	var names []string
+	for _, fd := range r.FieldDescriptions() {
+		names = append(names, fd.Name)
+	}
+
+	return names, nil
+}
+
+func (r pgxRows) Close() error {
+	r.Rows.Close()
+	return nil
+}
+
+// pgxDuration is encoded as milliseconds for bigint columns
+// and as native value for interval columns
+type pgxDuration struct {
+	d time.Duration
+}
+
+func (d pgxDuration) Int64Value() (pgtype.Int8, error) {
+	return pgtype.Int8{Int64: d.d.Milliseconds(), Valid: true}, nil
+}
+
+func (d pgxDuration) IntervalValue() (pgtype.Interval, error) {
+	return pgtype.Interval{Microseconds: d.d.Microseconds(), Valid: true}, nil
+}
+
+type pgxDurationScanner struct {
+	d **durationpb.Duration
+}
+
+func (s *pgxDurationScanner) ScanInt64(v pgtype.Int8) error {
+	if !v.Valid {
+		return nil
+	}
+
+	*s.d = durationpb.New(time.Duration(v.Int64) * time.Millisecond)
+	return nil
+}
+
+func (s *pgxDurationScanner) ScanInterval(v pgtype.Interval) error {
+	if !v.Valid {
+		return nil
+	}
+
+	const day = 24 * time.Hour
+	d := time.Duration(v.Microseconds)*time.Microsecond +
+		time.Duration(v.Days)*day +
+		time.Duration(v.Months)*30*day
+
+	*s.d = durationpb.New(d)
+	return nil
+}
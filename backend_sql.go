@@ -0,0 +1,118 @@
+package protosql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type sqlBackend struct {
+	db *sql.DB
+}
+
+type dbExec interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+func (b *sqlBackend) getDB(ctx context.Context) (dbExec, error) {
+	v := ctx.Value(txCtxKey)
+	if v == nil {
+		return b.db, nil
+	}
+
+	tx, ok := v.(*sql.Tx)
+	if !ok {
+		return nil, ErrForeignTx
+	}
+
+	return tx, nil
+}
+
+func (b *sqlBackend) exec(ctx context.Context, q string, args ...interface{}) (int64, error) {
+	db, err := b.getDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	ra, _ := res.RowsAffected()
+
+	return ra, nil
+}
+
+func (b *sqlBackend) query(ctx context.Context, q string, args ...interface{}) (rows, error) {
+	db, err := b.getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.QueryContext(ctx, q, args...)
+}
+
+func (b *sqlBackend) execBatch(ctx context.Context, q string, params [][]interface{}) error {
+	db, err := b.getDB(ctx)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := db.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range params {
+		_, err = stmt.ExecContext(ctx, row...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *sqlBackend) inTx(ctx context.Context) (bool, error) {
+	_, err := b.getDB(ctx)
+	return ctx.Value(txCtxKey) != nil && err == nil, err
+}
+
+func (b *sqlBackend) begin(ctx context.Context) (context.Context, dbTx, error) {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	return context.WithValue(ctx, txCtxKey, tx), sqlTx{tx}, nil
+}
+
+func (b *sqlBackend) ArrayArg(v interface{}) interface{} {
+	return pq.Array(v)
+}
+
+func (b *sqlBackend) DurationArg(d time.Duration) interface{} {
+	return d.Milliseconds()
+}
+
+func (b *sqlBackend) ArrayDest(dest interface{}) interface{} {
+	return &arrayScanner{dest}
+}
+
+func (b *sqlBackend) DurationDest(d **durationpb.Duration) interface{} {
+	return &durationScanner{d}
+}
+
+type sqlTx struct {
+	tx *sql.Tx
+}
+
+func (t sqlTx) commit() error   { return t.tx.Commit() }
+func (t sqlTx) rollback() error { return t.tx.Rollback() }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"github.com/fabregas/protosql"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	eqOps      = []string{"Eq", "Neq"}
+	compareOps = []string{"Gt", "Gte", "Lt", "Lte"}
+	arrOps     = []string{"ArrContain", "ArrOverlap"}
+)
+
+// goScalarType returns Go type of filter argument for field kind
+// and conversion of argument (enums are passed as int32, so UNSPECIFIED value is not ignored)
+func goScalarType(g *protogen.GeneratedFile, field *protogen.Field) (string, string) {
+	switch field.Desc.Kind() {
+	case protoreflect.StringKind:
+		return "string", ""
+	case protoreflect.BoolKind:
+		return "bool", ""
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return "int32", ""
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return "int64", ""
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return "uint32", ""
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return "uint64", ""
+	case protoreflect.FloatKind:
+		return "float32", ""
+	case protoreflect.DoubleKind:
+		return "float64", ""
+	case protoreflect.EnumKind:
+		return g.QualifiedGoIdent(field.Enum.GoIdent), "int32"
+	case protoreflect.MessageKind:
+		if protosql.FieldColumnKind(field.Desc) == protosql.TimeColumn {
+			return "*" + g.QualifiedGoIdent(field.Message.GoIdent), ""
+		}
+	}
+
+	return "", ""
+}
+
+func generateFilter(g *protogen.GeneratedFile, m *protogen.Message, fields []*protogen.Field) {
+	name := m.GoIdent.GoName + "Filter"
+	filter := g.QualifiedGoIdent(protosqlPackage.Ident("Filter"))
+
+	g.P("// ", name, " is a typed protosql.Filter for ", m.GoIdent.GoName)
+	g.P("type ", name, " struct {")
+	g.P("*", filter)
+	g.P("}")
+	g.P()
+	g.P("func New", name, "() *", name, " {")
+	g.P("return &", name, "{", protosqlPackage.Ident("NewFilter"), "()}")
+	g.P("}")
+	g.P()
+	g.P("func (f *", name, ") filter() *", filter, " {")
+	g.P("if f == nil {")
+	g.P("return nil")
+	g.P("}")
+	g.P("return f.Filter")
+	g.P("}")
+	g.P()
+
+	method := func(field *protogen.Field, op, args, call string) {
+		g.P("func (f *", name, ") ", field.GoName, op, "(", args, ") *", name, " {")
+		g.P("f.Filter.", call)
+		g.P("return f")
+		g.P("}")
+		g.P()
+	}
+
+	for _, field := range fields {
+		d := field.Desc
+		if d.HasOptionalKeyword() || d.IsMap() {
+			continue
+		}
+
+		col := columnConst(m, field)
+		typ, conv := goScalarType(g, field)
+		if typ == "" {
+			continue
+		}
+
+		if d.IsList() {
+			if d.Kind() == protoreflect.MessageKind {
+				continue
+			}
+			for _, op := range arrOps {
+				method(field, op, "v ..."+typ, op+"("+col+", "+sliceExpr(conv)+")")
+			}
+			method(field, "Empty", "", "ArrEmpty("+col+")")
+			continue
+		}
+
+		val := "v"
+		if conv != "" {
+			val = conv + "(v)"
+		}
+
+		for _, op := range eqOps {
+			method(field, op, "v "+typ, op+"("+col+", "+val+")")
+		}
+
+		if d.Kind() == protoreflect.BoolKind {
+			continue
+		}
+
+		if d.Kind() != protoreflect.MessageKind {
+			method(field, "In", "v ..."+typ, "In("+col+", "+sliceExpr(conv)+")")
+		}
+
+		if d.Kind() == protoreflect.StringKind {
+			method(field, "Contain", "v string", "Contain("+col+", v)")
+			continue
+		}
+
+		if d.Kind() != protoreflect.EnumKind {
+			for _, op := range compareOps {
+				method(field, op, "v "+typ, op+"("+col+", "+val+")")
+			}
+		}
+	}
+}
+
+// sliceExpr converts variadic argument v into slice supported by protosql.Filter
+func sliceExpr(conv string) string {
+	if conv == "" {
+		return "v"
+	}
+
+	return "func() []" + conv + " {\nr := make([]" + conv + ", len(v))\nfor i := range v {\nr[i] = " + conv + "(v[i])\n}\nreturn r\n}()"
+}
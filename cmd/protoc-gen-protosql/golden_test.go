@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fabregas/protosql"
+	"github.com/fabregas/protosql/cmd/protoc-gen-protosql/internal/golden"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// golden output is compiled as part of internal/golden package,
+// run go test -update after changing generator
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGolden(t *testing.T) {
+	fd := golden.File_golden_proto
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{fd.Path()},
+		Parameter:      proto.String("paths=source_relative"),
+		ProtoFile:      fileProtos(fd, map[string]bool{}),
+	}
+
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatalf("protogen.New() failed: %s", err)
+	}
+	if err := generate(gen); err != nil {
+		t.Fatalf("generate() failed: %s", err)
+	}
+
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatalf("generate() failed: %s", resp.GetError())
+	}
+	if len(resp.File) != 1 {
+		t.Fatalf("expected 1 generated file, got %d", len(resp.File))
+	}
+
+	path := filepath.Join("internal", "golden", resp.File[0].GetName())
+	if *update {
+		if err := os.WriteFile(path, []byte(resp.File[0].GetContent()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(expected) != resp.File[0].GetContent() {
+		t.Errorf("generated code differs from %s (run go test -update)", path)
+	}
+}
+
+// fileProtos returns descriptors of file and its imports, imports first
+func fileProtos(fd protoreflect.FileDescriptor, seen map[string]bool) []*descriptorpb.FileDescriptorProto {
+	if seen[fd.Path()] {
+		return nil
+	}
+	seen[fd.Path()] = true
+
+	var ret []*descriptorpb.FileDescriptorProto
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		ret = append(ret, fileProtos(imports.Get(i).FileDescriptor, seen)...)
+	}
+
+	return append(ret, protodesc.ToFileDescriptorProto(fd))
+}
+
+func TestGeneratedCode(t *testing.T) {
+	var m protosql.GeneratedModel = &golden.Project{}
+	var c testCodec
+
+	if n := len(m.SQLColumns()); len(m.SQLParams(c)) != n || len(m.SQLScanDest(c)) != n {
+		t.Errorf("columns, params and scan destinations differ in length")
+	}
+
+	q, args, err := golden.NewProjectFilter().NameEq("x").StatusIn(golden.Status_STATUS_ACTIVE).TagsArrContain("a").WhereQuery()
+	if err != nil {
+		t.Fatalf("WhereQuery() failed: %s", err)
+	}
+	if expected := " WHERE name = $1 AND status = ANY($2) AND tags::text[] @> $3::text[] "; q != expected {
+		t.Errorf("%q != %q", q, expected)
+	}
+	if len(args) != 3 {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+type testCodec struct{}
+
+func (testCodec) ArrayArg(v interface{}) interface{}               { return v }
+func (testCodec) DurationArg(d time.Duration) interface{}          { return d.Milliseconds() }
+func (testCodec) ArrayDest(dest interface{}) interface{}           { return dest }
+func (testCodec) DurationDest(d **durationpb.Duration) interface{} { return d }
@@ -0,0 +1,515 @@
+// Code generated by protoc-gen-protosql. DO NOT EDIT.
+// source: golden.proto
+
+package golden
+
+import (
+	context "context"
+	protosql "github.com/fabregas/protosql"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Column names of Item
+const (
+	ItemColumnNum  = "num"
+	ItemColumnName = "name"
+)
+
+func (x *Item) SQLColumns() []string {
+	return []string{
+		ItemColumnNum,
+		ItemColumnName,
+	}
+}
+
+func (x *Item) SQLParams(c protosql.Codec) []interface{} {
+	return []interface{}{
+		x.Num,
+		x.Name,
+	}
+}
+
+func (x *Item) SQLScanDest(c protosql.Codec) []interface{} {
+	return []interface{}{
+		&x.Num,
+		&x.Name,
+	}
+}
+
+// ItemFilter is a typed protosql.Filter for Item
+type ItemFilter struct {
+	*protosql.Filter
+}
+
+func NewItemFilter() *ItemFilter {
+	return &ItemFilter{protosql.NewFilter()}
+}
+
+func (f *ItemFilter) filter() *protosql.Filter {
+	if f == nil {
+		return nil
+	}
+	return f.Filter
+}
+
+func (f *ItemFilter) NumEq(v int32) *ItemFilter {
+	f.Filter.Eq(ItemColumnNum, v)
+	return f
+}
+
+func (f *ItemFilter) NumNeq(v int32) *ItemFilter {
+	f.Filter.Neq(ItemColumnNum, v)
+	return f
+}
+
+func (f *ItemFilter) NumIn(v ...int32) *ItemFilter {
+	f.Filter.In(ItemColumnNum, v)
+	return f
+}
+
+func (f *ItemFilter) NumGt(v int32) *ItemFilter {
+	f.Filter.Gt(ItemColumnNum, v)
+	return f
+}
+
+func (f *ItemFilter) NumGte(v int32) *ItemFilter {
+	f.Filter.Gte(ItemColumnNum, v)
+	return f
+}
+
+func (f *ItemFilter) NumLt(v int32) *ItemFilter {
+	f.Filter.Lt(ItemColumnNum, v)
+	return f
+}
+
+func (f *ItemFilter) NumLte(v int32) *ItemFilter {
+	f.Filter.Lte(ItemColumnNum, v)
+	return f
+}
+
+func (f *ItemFilter) NameEq(v string) *ItemFilter {
+	f.Filter.Eq(ItemColumnName, v)
+	return f
+}
+
+func (f *ItemFilter) NameNeq(v string) *ItemFilter {
+	f.Filter.Neq(ItemColumnName, v)
+	return f
+}
+
+func (f *ItemFilter) NameIn(v ...string) *ItemFilter {
+	f.Filter.In(ItemColumnName, v)
+	return f
+}
+
+func (f *ItemFilter) NameContain(v string) *ItemFilter {
+	f.Filter.Contain(ItemColumnName, v)
+	return f
+}
+
+// ItemRepo is a typed wrapper of protosql.Repo for Item
+type ItemRepo struct {
+	*protosql.Repo
+}
+
+func NewItemRepo(r *protosql.Repo) *ItemRepo {
+	return &ItemRepo{r}
+}
+
+func (r *ItemRepo) Insert(ctx context.Context, obj *Item) error {
+	return r.Repo.Insert(ctx, obj)
+}
+
+func (r *ItemRepo) Get(ctx context.Context, id interface{}) (*Item, error) {
+	obj := &Item{}
+	if err := r.FindByID(ctx, id).FetchOne(obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// s must be *protosql.Sorting or sorting proto message
+func (r *ItemRepo) List(ctx context.Context, f *ItemFilter, s interface{}, p protosql.Pager) ([]*Item, error) {
+	var ret []*Item
+	q := r.Select(ctx).Where(f.filter()).Paginate(p)
+	if s != nil {
+		q = q.OrderBy(s)
+	}
+	if err := q.Fetch(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (r *ItemRepo) Update(ctx context.Context, obj *Item, f *ItemFilter) error {
+	return r.Repo.Update(ctx, obj, f.filter())
+}
+
+func (r *ItemRepo) Delete(ctx context.Context, f *ItemFilter) error {
+	return r.Repo.Delete(ctx, f.filter())
+}
+
+// Column names of Project
+const (
+	ProjectColumnId          = "id"
+	ProjectColumnName        = "name"
+	ProjectColumnPublic      = "public"
+	ProjectColumnStars       = "stars"
+	ProjectColumnScore       = "score"
+	ProjectColumnLogo        = "logo"
+	ProjectColumnStatus      = "status"
+	ProjectColumnCreateTime  = "create_time"
+	ProjectColumnTimeout     = "timeout"
+	ProjectColumnTags        = "tags"
+	ProjectColumnOldStatuses = "old_statuses"
+	ProjectColumnMainItem    = "main_item"
+	ProjectColumnItems       = "items"
+	ProjectColumnLabels      = "labels"
+	ProjectColumnOwner       = "owner"
+)
+
+func (x *Project) SQLColumns() []string {
+	return []string{
+		ProjectColumnId,
+		ProjectColumnName,
+		ProjectColumnPublic,
+		ProjectColumnStars,
+		ProjectColumnScore,
+		ProjectColumnLogo,
+		ProjectColumnStatus,
+		ProjectColumnCreateTime,
+		ProjectColumnTimeout,
+		ProjectColumnTags,
+		ProjectColumnOldStatuses,
+		ProjectColumnMainItem,
+		ProjectColumnItems,
+		ProjectColumnLabels,
+		ProjectColumnOwner,
+	}
+}
+
+func (x *Project) SQLParams(c protosql.Codec) []interface{} {
+	return []interface{}{
+		x.Id,
+		x.Name,
+		x.Public,
+		x.Stars,
+		x.Score,
+		x.Logo,
+		int32(x.Status),
+		protosql.TimeArg(x.CreateTime),
+		c.DurationArg(x.Timeout.AsDuration()),
+		c.ArrayArg(x.Tags),
+		c.ArrayArg(x.OldStatuses),
+		protosql.JSONArg(x.MainItem),
+		protosql.JSONArg(x.Items),
+		protosql.JSONArg(x.Labels),
+		protosql.JSONArg(x.Owner),
+	}
+}
+
+func (x *Project) SQLScanDest(c protosql.Codec) []interface{} {
+	return []interface{}{
+		&x.Id,
+		&x.Name,
+		&x.Public,
+		&x.Stars,
+		&x.Score,
+		&x.Logo,
+		&x.Status,
+		protosql.TimeDest(&x.CreateTime),
+		c.DurationDest(&x.Timeout),
+		c.ArrayDest(&x.Tags),
+		c.ArrayDest(&x.OldStatuses),
+		protosql.JSONDest(&x.MainItem),
+		protosql.JSONDest(&x.Items),
+		protosql.JSONDest(&x.Labels),
+		protosql.JSONDest(&x.Owner),
+	}
+}
+
+// ProjectFilter is a typed protosql.Filter for Project
+type ProjectFilter struct {
+	*protosql.Filter
+}
+
+func NewProjectFilter() *ProjectFilter {
+	return &ProjectFilter{protosql.NewFilter()}
+}
+
+func (f *ProjectFilter) filter() *protosql.Filter {
+	if f == nil {
+		return nil
+	}
+	return f.Filter
+}
+
+func (f *ProjectFilter) IdEq(v int64) *ProjectFilter {
+	f.Filter.Eq(ProjectColumnId, v)
+	return f
+}
+
+func (f *ProjectFilter) IdNeq(v int64) *ProjectFilter {
+	f.Filter.Neq(ProjectColumnId, v)
+	return f
+}
+
+func (f *ProjectFilter) IdIn(v ...int64) *ProjectFilter {
+	f.Filter.In(ProjectColumnId, v)
+	return f
+}
+
+func (f *ProjectFilter) IdGt(v int64) *ProjectFilter {
+	f.Filter.Gt(ProjectColumnId, v)
+	return f
+}
+
+func (f *ProjectFilter) IdGte(v int64) *ProjectFilter {
+	f.Filter.Gte(ProjectColumnId, v)
+	return f
+}
+
+func (f *ProjectFilter) IdLt(v int64) *ProjectFilter {
+	f.Filter.Lt(ProjectColumnId, v)
+	return f
+}
+
+func (f *ProjectFilter) IdLte(v int64) *ProjectFilter {
+	f.Filter.Lte(ProjectColumnId, v)
+	return f
+}
+
+func (f *ProjectFilter) NameEq(v string) *ProjectFilter {
+	f.Filter.Eq(ProjectColumnName, v)
+	return f
+}
+
+func (f *ProjectFilter) NameNeq(v string) *ProjectFilter {
+	f.Filter.Neq(ProjectColumnName, v)
+	return f
+}
+
+func (f *ProjectFilter) NameIn(v ...string) *ProjectFilter {
+	f.Filter.In(ProjectColumnName, v)
+	return f
+}
+
+func (f *ProjectFilter) NameContain(v string) *ProjectFilter {
+	f.Filter.Contain(ProjectColumnName, v)
+	return f
+}
+
+func (f *ProjectFilter) PublicEq(v bool) *ProjectFilter {
+	f.Filter.Eq(ProjectColumnPublic, v)
+	return f
+}
+
+func (f *ProjectFilter) PublicNeq(v bool) *ProjectFilter {
+	f.Filter.Neq(ProjectColumnPublic, v)
+	return f
+}
+
+func (f *ProjectFilter) StarsEq(v uint32) *ProjectFilter {
+	f.Filter.Eq(ProjectColumnStars, v)
+	return f
+}
+
+func (f *ProjectFilter) StarsNeq(v uint32) *ProjectFilter {
+	f.Filter.Neq(ProjectColumnStars, v)
+	return f
+}
+
+func (f *ProjectFilter) StarsIn(v ...uint32) *ProjectFilter {
+	f.Filter.In(ProjectColumnStars, v)
+	return f
+}
+
+func (f *ProjectFilter) StarsGt(v uint32) *ProjectFilter {
+	f.Filter.Gt(ProjectColumnStars, v)
+	return f
+}
+
+func (f *ProjectFilter) StarsGte(v uint32) *ProjectFilter {
+	f.Filter.Gte(ProjectColumnStars, v)
+	return f
+}
+
+func (f *ProjectFilter) StarsLt(v uint32) *ProjectFilter {
+	f.Filter.Lt(ProjectColumnStars, v)
+	return f
+}
+
+func (f *ProjectFilter) StarsLte(v uint32) *ProjectFilter {
+	f.Filter.Lte(ProjectColumnStars, v)
+	return f
+}
+
+func (f *ProjectFilter) ScoreEq(v float64) *ProjectFilter {
+	f.Filter.Eq(ProjectColumnScore, v)
+	return f
+}
+
+func (f *ProjectFilter) ScoreNeq(v float64) *ProjectFilter {
+	f.Filter.Neq(ProjectColumnScore, v)
+	return f
+}
+
+func (f *ProjectFilter) ScoreIn(v ...float64) *ProjectFilter {
+	f.Filter.In(ProjectColumnScore, v)
+	return f
+}
+
+func (f *ProjectFilter) ScoreGt(v float64) *ProjectFilter {
+	f.Filter.Gt(ProjectColumnScore, v)
+	return f
+}
+
+func (f *ProjectFilter) ScoreGte(v float64) *ProjectFilter {
+	f.Filter.Gte(ProjectColumnScore, v)
+	return f
+}
+
+func (f *ProjectFilter) ScoreLt(v float64) *ProjectFilter {
+	f.Filter.Lt(ProjectColumnScore, v)
+	return f
+}
+
+func (f *ProjectFilter) ScoreLte(v float64) *ProjectFilter {
+	f.Filter.Lte(ProjectColumnScore, v)
+	return f
+}
+
+func (f *ProjectFilter) StatusEq(v Status) *ProjectFilter {
+	f.Filter.Eq(ProjectColumnStatus, int32(v))
+	return f
+}
+
+func (f *ProjectFilter) StatusNeq(v Status) *ProjectFilter {
+	f.Filter.Neq(ProjectColumnStatus, int32(v))
+	return f
+}
+
+func (f *ProjectFilter) StatusIn(v ...Status) *ProjectFilter {
+	f.Filter.In(ProjectColumnStatus, func() []int32 {
+		r := make([]int32, len(v))
+		for i := range v {
+			r[i] = int32(v[i])
+		}
+		return r
+	}())
+	return f
+}
+
+func (f *ProjectFilter) CreateTimeEq(v *timestamppb.Timestamp) *ProjectFilter {
+	f.Filter.Eq(ProjectColumnCreateTime, v)
+	return f
+}
+
+func (f *ProjectFilter) CreateTimeNeq(v *timestamppb.Timestamp) *ProjectFilter {
+	f.Filter.Neq(ProjectColumnCreateTime, v)
+	return f
+}
+
+func (f *ProjectFilter) CreateTimeGt(v *timestamppb.Timestamp) *ProjectFilter {
+	f.Filter.Gt(ProjectColumnCreateTime, v)
+	return f
+}
+
+func (f *ProjectFilter) CreateTimeGte(v *timestamppb.Timestamp) *ProjectFilter {
+	f.Filter.Gte(ProjectColumnCreateTime, v)
+	return f
+}
+
+func (f *ProjectFilter) CreateTimeLt(v *timestamppb.Timestamp) *ProjectFilter {
+	f.Filter.Lt(ProjectColumnCreateTime, v)
+	return f
+}
+
+func (f *ProjectFilter) CreateTimeLte(v *timestamppb.Timestamp) *ProjectFilter {
+	f.Filter.Lte(ProjectColumnCreateTime, v)
+	return f
+}
+
+func (f *ProjectFilter) TagsArrContain(v ...string) *ProjectFilter {
+	f.Filter.ArrContain(ProjectColumnTags, v)
+	return f
+}
+
+func (f *ProjectFilter) TagsArrOverlap(v ...string) *ProjectFilter {
+	f.Filter.ArrOverlap(ProjectColumnTags, v)
+	return f
+}
+
+func (f *ProjectFilter) TagsEmpty() *ProjectFilter {
+	f.Filter.ArrEmpty(ProjectColumnTags)
+	return f
+}
+
+func (f *ProjectFilter) OldStatusesArrContain(v ...Status) *ProjectFilter {
+	f.Filter.ArrContain(ProjectColumnOldStatuses, func() []int32 {
+		r := make([]int32, len(v))
+		for i := range v {
+			r[i] = int32(v[i])
+		}
+		return r
+	}())
+	return f
+}
+
+func (f *ProjectFilter) OldStatusesArrOverlap(v ...Status) *ProjectFilter {
+	f.Filter.ArrOverlap(ProjectColumnOldStatuses, func() []int32 {
+		r := make([]int32, len(v))
+		for i := range v {
+			r[i] = int32(v[i])
+		}
+		return r
+	}())
+	return f
+}
+
+func (f *ProjectFilter) OldStatusesEmpty() *ProjectFilter {
+	f.Filter.ArrEmpty(ProjectColumnOldStatuses)
+	return f
+}
+
+// ProjectRepo is a typed wrapper of protosql.Repo for Project
+type ProjectRepo struct {
+	*protosql.Repo
+}
+
+func NewProjectRepo(r *protosql.Repo) *ProjectRepo {
+	return &ProjectRepo{r}
+}
+
+func (r *ProjectRepo) Insert(ctx context.Context, obj *Project) error {
+	return r.Repo.Insert(ctx, obj)
+}
+
+func (r *ProjectRepo) Get(ctx context.Context, id interface{}) (*Project, error) {
+	obj := &Project{}
+	if err := r.FindByID(ctx, id).FetchOne(obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// s must be *protosql.Sorting or sorting proto message
+func (r *ProjectRepo) List(ctx context.Context, f *ProjectFilter, s interface{}, p protosql.Pager) ([]*Project, error) {
+	var ret []*Project
+	q := r.Select(ctx).Where(f.filter()).Paginate(p)
+	if s != nil {
+		q = q.OrderBy(s)
+	}
+	if err := q.Fetch(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (r *ProjectRepo) Update(ctx context.Context, obj *Project, f *ProjectFilter) error {
+	return r.Repo.Update(ctx, obj, f.filter())
+}
+
+func (r *ProjectRepo) Delete(ctx context.Context, f *ProjectFilter) error {
+	return r.Repo.Delete(ctx, f.filter())
+}
@@ -0,0 +1,200 @@
+// protoc-gen-protosql generates column constants, typed filters, typed repositories
+// and reflection free param/scan functions for proto messages.
+//
+// Usage:
+//
+//	protoc --go_out=. --protosql_out=. api.proto
+package main
+
+import (
+	"github.com/fabregas/protosql"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func main() {
+	protogen.Options{}.Run(generate)
+}
+
+func generate(gen *protogen.Plugin) error {
+	for _, f := range gen.Files {
+		if !f.Generate || len(f.Messages) == 0 {
+			continue
+		}
+		generateFile(gen, f)
+	}
+	gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+	return nil
+}
+
+const (
+	protosqlPackage = protogen.GoImportPath("github.com/fabregas/protosql")
+	contextPackage  = protogen.GoImportPath("context")
+)
+
+func generateFile(gen *protogen.Plugin, f *protogen.File) {
+	g := gen.NewGeneratedFile(f.GeneratedFilenamePrefix+"_protosql.pb.go", f.GoImportPath)
+	g.P("// Code generated by protoc-gen-protosql. DO NOT EDIT.")
+	g.P("// source: ", f.Desc.Path())
+	g.P()
+	g.P("package ", f.GoPackageName)
+	g.P()
+
+	for _, m := range f.Messages {
+		if m.Desc.IsMapEntry() {
+			continue
+		}
+		generateMessage(g, m)
+	}
+}
+
+// columns returns fields mapped to table columns (the same as protosql parseProtoMsg does)
+func columns(m *protogen.Message) []*protogen.Field {
+	var ret []*protogen.Field
+	for _, field := range m.Fields {
+		if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
+			continue
+		}
+		ret = append(ret, field)
+	}
+
+	return ret
+}
+
+func columnConst(m *protogen.Message, field *protogen.Field) string {
+	return m.GoIdent.GoName + "Column" + field.GoName
+}
+
+func generateMessage(g *protogen.GeneratedFile, m *protogen.Message) {
+	name := m.GoIdent.GoName
+	fields := columns(m)
+	if len(fields) == 0 {
+		return
+	}
+
+	g.P("// Column names of ", name)
+	g.P("const (")
+	for _, field := range fields {
+		g.P(columnConst(m, field), " = ", `"`, field.Desc.Name(), `"`)
+	}
+	g.P(")")
+	g.P()
+
+	codec := g.QualifiedGoIdent(protosqlPackage.Ident("Codec"))
+
+	g.P("func (x *", name, ") SQLColumns() []string {")
+	g.P("return []string{")
+	for _, field := range fields {
+		g.P(columnConst(m, field), ",")
+	}
+	g.P("}")
+	g.P("}")
+	g.P()
+
+	g.P("func (x *", name, ") SQLParams(c ", codec, ") []interface{} {")
+	g.P("return []interface{}{")
+	for _, field := range fields {
+		g.P(paramExpr(g, field), ",")
+	}
+	g.P("}")
+	g.P("}")
+	g.P()
+
+	g.P("func (x *", name, ") SQLScanDest(c ", codec, ") []interface{} {")
+	g.P("return []interface{}{")
+	for _, field := range fields {
+		g.P(destExpr(g, field), ",")
+	}
+	g.P("}")
+	g.P("}")
+	g.P()
+
+	generateFilter(g, m, fields)
+	generateRepo(g, m)
+}
+
+func paramExpr(g *protogen.GeneratedFile, field *protogen.Field) string {
+	v := "x." + field.GoName
+
+	switch protosql.FieldColumnKind(field.Desc) {
+	case protosql.JSONColumn:
+		return g.QualifiedGoIdent(protosqlPackage.Ident("JSONArg")) + "(" + v + ")"
+	case protosql.ArrayColumn:
+		return "c.ArrayArg(" + v + ")"
+	case protosql.TimeColumn:
+		return g.QualifiedGoIdent(protosqlPackage.Ident("TimeArg")) + "(" + v + ")"
+	case protosql.DurationColumn:
+		return "c.DurationArg(" + v + ".AsDuration())"
+	case protosql.EnumColumn:
+		return "int32(" + v + ")"
+	default:
+		return v
+	}
+}
+
+func destExpr(g *protogen.GeneratedFile, field *protogen.Field) string {
+	v := "&x." + field.GoName
+
+	switch protosql.FieldColumnKind(field.Desc) {
+	case protosql.JSONColumn:
+		return g.QualifiedGoIdent(protosqlPackage.Ident("JSONDest")) + "(" + v + ")"
+	case protosql.ArrayColumn:
+		return "c.ArrayDest(" + v + ")"
+	case protosql.TimeColumn:
+		return g.QualifiedGoIdent(protosqlPackage.Ident("TimeDest")) + "(" + v + ")"
+	case protosql.DurationColumn:
+		return "c.DurationDest(" + v + ")"
+	default:
+		return v
+	}
+}
+
+func generateRepo(g *protogen.GeneratedFile, m *protogen.Message) {
+	name := m.GoIdent.GoName
+	repo := name + "Repo"
+	filter := name + "Filter"
+	ctx := g.QualifiedGoIdent(contextPackage.Ident("Context"))
+
+	g.P("// ", repo, " is a typed wrapper of protosql.Repo for ", name)
+	g.P("type ", repo, " struct {")
+	g.P("*", protosqlPackage.Ident("Repo"))
+	g.P("}")
+	g.P()
+	g.P("func New", repo, "(r *", protosqlPackage.Ident("Repo"), ") *", repo, " {")
+	g.P("return &", repo, "{r}")
+	g.P("}")
+	g.P()
+	g.P("func (r *", repo, ") Insert(ctx ", ctx, ", obj *", name, ") error {")
+	g.P("return r.Repo.Insert(ctx, obj)")
+	g.P("}")
+	g.P()
+	g.P("func (r *", repo, ") Get(ctx ", ctx, ", id interface{}) (*", name, ", error) {")
+	g.P("obj := &", name, "{}")
+	g.P("if err := r.FindByID(ctx, id).FetchOne(obj); err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P("return obj, nil")
+	g.P("}")
+	g.P()
+	g.P("// s must be *protosql.Sorting or sorting proto message")
+	g.P("func (r *", repo, ") List(ctx ", ctx, ", f *", filter, ", s interface{}, p ", protosqlPackage.Ident("Pager"), ") ([]*", name, ", error) {")
+	g.P("var ret []*", name)
+	g.P("q := r.Select(ctx).Where(f.filter()).Paginate(p)")
+	g.P("if s != nil {")
+	g.P("q = q.OrderBy(s)")
+	g.P("}")
+	g.P("if err := q.Fetch(&ret); err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P("return ret, nil")
+	g.P("}")
+	g.P()
+	g.P("func (r *", repo, ") Update(ctx ", ctx, ", obj *", name, ", f *", filter, ") error {")
+	g.P("return r.Repo.Update(ctx, obj, f.filter())")
+	g.P("}")
+	g.P()
+	g.P("func (r *", repo, ") Delete(ctx ", ctx, ", f *", filter, ") error {")
+	g.P("return r.Repo.Delete(ctx, f.filter())")
+	g.P("}")
+	g.P()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fabregas/protosql"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// columnsList is a repeatable flag with comma separated column names
+type columnsList [][]string
+
+func (l *columnsList) String() string {
+	var parts []string
+	for _, cols := range *l {
+		parts = append(parts, strings.Join(cols, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (l *columnsList) Set(v string) error {
+	*l = append(*l, strings.Split(v, ","))
+	return nil
+}
+
+type schemaFlags struct {
+	descriptorSet string
+	message       string
+	table         string
+	pk            string
+	nullable      string
+	indexes       columnsList
+	uniques       columnsList
+}
+
+func (f *schemaFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.descriptorSet, "descriptor_set", "", "file produced by protoc --include_imports --descriptor_set_out")
+	fs.StringVar(&f.message, "message", "", "full name of proto message (e.g. some.v1.Project)")
+	fs.StringVar(&f.table, "table", "", "table name")
+	fs.StringVar(&f.pk, "pk", "", "comma separated primary key columns (default id)")
+	fs.StringVar(&f.nullable, "nullable", "", "comma separated columns without NOT NULL constraint")
+	fs.Var(&f.indexes, "index", "comma separated index columns (repeatable)")
+	fs.Var(&f.uniques, "unique", "comma separated unique constraint columns (repeatable)")
+}
+
+func (f *schemaFlags) schema() (*protosql.Schema, error) {
+	if f.descriptorSet == "" || f.message == "" || f.table == "" {
+		return nil, fmt.Errorf("-descriptor_set, -message and -table are required")
+	}
+
+	md, err := loadMessage(f.descriptorSet, f.message)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []protosql.SchemaOption
+	if f.pk != "" {
+		opts = append(opts, protosql.PrimaryKey(strings.Split(f.pk, ",")...))
+	}
+	if f.nullable != "" {
+		opts = append(opts, protosql.Nullable(strings.Split(f.nullable, ",")...))
+	}
+	for _, cols := range f.indexes {
+		opts = append(opts, protosql.WithIndex(cols...))
+	}
+	for _, cols := range f.uniques {
+		opts = append(opts, protosql.WithUnique(cols...))
+	}
+
+	return protosql.TableSchemaFromDescriptor(f.table, md, opts...), nil
+}
+
+func loadMessage(path, name string) (protoreflect.MessageDescriptor, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("message %s: %w", name, err)
+	}
+
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", name)
+	}
+
+	return md, nil
+}
+
+func runDDL(args []string) error {
+	fs := flag.NewFlagSet("ddl", flag.ExitOnError)
+	var f schemaFlags
+	f.register(fs)
+	fs.Parse(args)
+
+	s, err := f.schema()
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(s.DDL())
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fabregas/protosql"
+	_ "github.com/lib/pq"
+)
+
+func openRepo(dsn, table string) (*protosql.Repo, func(), error) {
+	if dsn == "" {
+		return nil, nil, fmt.Errorf("-dsn is required")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return protosql.NewRepo(db, table, protosql.DummyModel{}, stderrLogger{}), func() { db.Close() }, nil
+}
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var f schemaFlags
+	f.register(fs)
+	dsn := fs.String("dsn", os.Getenv("DATABASE_URL"), "database connection string")
+	dir := fs.String("dir", "", "write migration files into directory instead of stdout")
+	name := fs.String("name", "", "migration name (default update_<table>)")
+	fs.Parse(args)
+
+	target, err := f.schema()
+	if err != nil {
+		return err
+	}
+
+	r, closeDB, err := openRepo(*dsn, f.table)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	changes, err := r.SchemaDiff(context.Background(), target)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintln(os.Stderr, "schema is up to date")
+		return nil
+	}
+
+	for _, c := range changes {
+		if c.Destructive {
+			fmt.Fprintf(os.Stderr, "WARNING: destructive change: %s\n", c.Up)
+		}
+	}
+
+	if *dir == "" {
+		for _, c := range changes {
+			fmt.Println(c.Up)
+		}
+		return nil
+	}
+
+	if *name == "" {
+		*name = "update_" + f.table
+	}
+
+	path, err := protosql.WriteMigration(*dir, *name, changes)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "migration written to %s\n", path)
+	return nil
+}
+
+type stderrLogger struct{}
+
+func (l stderrLogger) Debugf(format string, args ...interface{}) {}
+func (l stderrLogger) Infof(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+func (l stderrLogger) Errorf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ERROR: "+format+"\n", args...)
+}
@@ -0,0 +1,59 @@
+// protosql is a command line tool for managing SQL schema of protobuf models.
+//
+// Usage:
+//
+//	protosql ddl -descriptor_set api.pb -message some.v1.Project -table projects [-index name] [-unique website]
+//	protosql diff -dsn postgres://... -descriptor_set api.pb -message some.v1.Project -table projects [-dir migrations]
+//	protosql migrate -dsn postgres://... -dir migrations status|up|down|redo
+//
+// Primary key, indexes, unique constraints and nullable columns can be declared
+// in proto by protosql.table and protosql.field options (options/options.proto),
+// flags are applied after them.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"ddl", "print CREATE TABLE statement for proto message", runDDL},
+	{"diff", "compare proto message with live table and generate migration", runDiff},
+	{"migrate", "apply or revert migrations (status|up|down|redo)", runMigrate},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+
+		if err := c.run(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "protosql %s: %s\n", c.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: protosql <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.usage)
+	}
+}
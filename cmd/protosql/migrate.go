@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fabregas/protosql"
+)
+
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dsn := fs.String("dsn", os.Getenv("DATABASE_URL"), "database connection string")
+	dir := fs.String("dir", "migrations", "directory with migration files")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: protosql migrate [flags] status|up|down|redo")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("action is required")
+	}
+
+	if *dsn == "" {
+		return fmt.Errorf("-dsn is required")
+	}
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	m := protosql.NewMigrator(db, os.DirFS(*dir), stderrLogger{})
+
+	switch fs.Arg(0) {
+	case "status":
+		lst, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range lst {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if st.Modified {
+				state += " (MODIFIED)"
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, state)
+		}
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%d migrations applied\n", n)
+	case "down":
+		return m.Down(ctx)
+	case "redo":
+		return m.Redo(ctx)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown action %s", fs.Arg(0))
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package protosql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// common table expressions (WITH name AS (...) SELECT ...)
+
+type cte struct {
+	name      string
+	recursive bool
+	build     func(idx int) (string, []interface{}, error) // idx is a first placeholder index
+}
+
+// With adds common table expression name built from sub query,
+// the name can be used as table in query, joins and filters
+func (q *repoQ) With(name string, sub *repoQ) *repoQ {
+	q.ctes = append(q.ctes, cte{name: name, build: func(idx int) (string, []interface{}, error) {
+		if len(sub.unionQueries) > 0 || sub.globalSearchTerm != "" {
+			return "", nil, errSubQuery
+		}
+		return sub.buildQ(idx, "", sub.pager)
+	}})
+	return q
+}
+
+// withClause returns WITH clause of query expressions
+func (q *repoQ) withClause(startIdx int) (string, []interface{}, error) {
+	var (
+		exprs     []string
+		args      []interface{}
+		recursive bool
+	)
+	for _, c := range q.ctes {
+		cq, cArgs, err := c.build(startIdx + len(args))
+		if err != nil {
+			return "", nil, fmt.Errorf("with %s: %w", c.name, err)
+		}
+		exprs = append(exprs, fmt.Sprintf("%s AS (%s)", c.name, cq))
+		args = append(args, cArgs...)
+		recursive = recursive || c.recursive
+	}
+
+	with := "WITH "
+	if recursive {
+		with = "WITH RECURSIVE "
+	}
+
+	return with + strings.Join(exprs, ", ") + " ", args, nil
+}
@@ -0,0 +1,66 @@
+package protosql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var errDistinctLock = errors.New("FOR UPDATE is not allowed with DISTINCT")
+
+// Distinct removes duplicate rows (e.g. produced by joins).
+// Sorting should be by selected columns.
+func (q *repoQ) Distinct() *repoQ {
+	q.distinct = true
+	return q
+}
+
+// DistinctOn keeps first row of each group of rows with equal columns values,
+// first row is defined by sorting (e.g. latest row per partner with OrderBy(Desc("create_time"))).
+// If sorting is not by one of columns, rows are sorted and paginated by outer query.
+func (q *repoQ) DistinctOn(columns ...string) *repoQ {
+	q.distinct = true
+	q.distinctOn = append(q.distinctOn, columns...)
+	return q
+}
+
+// leading SELECT keyword of query (DISTINCT is inserted after it)
+var selectPrefixRe = regexp.MustCompile(`(?i)^\s*SELECT\s+`)
+
+// distinctClause is not applied to aggregates (see Aggregate) and queries not starting with SELECT
+func (q *repoQ) distinctClause() string {
+	if !q.distinct || len(q.selectExprs) > 0 || (q.query != "" && !selectPrefixRe.MatchString(q.query)) {
+		return ""
+	}
+	if len(q.distinctOn) == 0 {
+		return "DISTINCT "
+	}
+	return fmt.Sprintf("DISTINCT ON (%s) ", strings.Join(q.distinctOn, ", "))
+}
+
+// distinctSort returns ORDER BY starting with DISTINCT ON columns (required by PostgreSQL)
+// and ORDER BY of outer query if sorting is not by one of columns
+func distinctSort(columns []string, s *Sorting, sq string) (string, string) {
+	if sq == "" {
+		return "", ""
+	}
+
+	keys := append([]string{}, columns...)
+	sort := strings.TrimPrefix(sq, " ORDER BY ")
+	if s.rank != nil || s.similar != nil {
+		// relevance is sorted within groups only
+		return " ORDER BY " + strings.Join(append(keys, sort), ", "), ""
+	}
+
+	for i, c := range keys {
+		if c == s.FieldName {
+			keys[i] = sort
+			return " ORDER BY " + strings.Join(keys, ", "), ""
+		}
+	}
+
+	// outer query selects columns without table prefix
+	field := s.FieldName[strings.LastIndex(s.FieldName, ".")+1:]
+	return " ORDER BY " + strings.Join(append(keys, sort), ", "), fmt.Sprintf(" ORDER BY %s %s", field, s.Order)
+}
@@ -5,4 +5,11 @@ import "errors"
 var (
 	ErrNotFound      = errors.New("not found")
 	ErrAlreadyExists = errors.New("already exists")
+
+	// ErrUnfiltered is returned by Update and Delete if filter has no effective conditions
+	ErrUnfiltered = errors.New("update or delete without conditions, use Filter.AllowAll")
+	// ErrIgnoredFilter is returned for ignored filter expression in strict mode
+	ErrIgnoredFilter = errors.New("filter expression is ignored")
+	// ErrForeignTx is returned if context holds transaction opened by Repo of another backend
+	ErrForeignTx = errors.New("transaction of another backend in context")
 )
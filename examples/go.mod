@@ -1,14 +1,26 @@
 module examples
 
-go 1.17
+go 1.19
 
 replace github.com/fabregas/protosql => ../
 
 require (
 	github.com/fabregas/protosql v0.0.2
-	github.com/lib/pq v1.10.4
+	github.com/lib/pq v1.10.5
 	github.com/sirupsen/logrus v1.8.1
 	google.golang.org/protobuf v1.27.1
 )
 
-require golang.org/x/sys v0.0.0-20191026070338-33540a1f6037 // indirect
+require (
+	github.com/hashicorp/go-immutable-radix v1.0.0 // indirect
+	github.com/hashicorp/go-metrics v0.5.4 // indirect
+	github.com/hashicorp/golang-lru v0.5.0 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/pgx/v5 v5.5.5 // indirect
+	github.com/jackc/puddle/v2 v2.2.1 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
@@ -3,10 +3,14 @@ package protosql
 import (
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
@@ -62,7 +66,28 @@ const (
 	arrEmptyOp
 	jsonArrEmptyOp
 
+	isNullOp
+	notNullOp
+	notInOp
+	betweenOp
+	prefixOp
+	suffixOp
+	likeOp
+	regexOp
+	iregexOp
+	eqFoldOp
+	matchOp
+	similarOp
+	jsonPathOp
+	jsonMsgContainOp
+	inQueryOp
+	existsOp
+	notExistsOp
+
+	invalidOp // rval is an error returned on query building
+
 	orOp
+	andOp
 
 	notOp
 
@@ -71,9 +96,9 @@ const (
 
 func (o operator) value() (s string) {
 	switch o {
-	case eqOp, emptyStrOp, arrEmptyOp, inOp:
+	case eqOp, emptyStrOp, arrEmptyOp, inOp, eqFoldOp:
 		s = "="
-	case neqOp, notEmptyStrOp:
+	case neqOp, notEmptyStrOp, notInOp:
 		s = "!="
 	case gtOp:
 		s = ">"
@@ -83,8 +108,28 @@ func (o operator) value() (s string) {
 		s = "<"
 	case lteOp:
 		s = "<="
-	case containOp:
+	case containOp, prefixOp, suffixOp:
 		s = "ILIKE"
+	case likeOp:
+		s = "LIKE"
+	case regexOp:
+		s = "~"
+	case iregexOp:
+		s = "~*"
+	case isNullOp:
+		s = "IS NULL"
+	case notNullOp:
+		s = "IS NOT NULL"
+	case betweenOp:
+		s = "BETWEEN"
+	case matchOp:
+		s = "@@"
+	case similarOp:
+		s = "%"
+	case jsonPathOp:
+		s = "@?"
+	case jsonMsgContainOp:
+		s = "@>"
 	case jsonArrInOp:
 		s = "?|"
 	case arrContainOp:
@@ -109,16 +154,217 @@ type filterExpr struct {
 }
 
 func (f filterExpr) formatStr(s string) string {
-	if f.op == containOp {
+	switch f.op {
+	case containOp:
 		return fmt.Sprintf("%%%s%%", s)
+	case prefixOp:
+		return escapeLike(s) + "%"
+	case suffixOp:
+		return "%" + escapeLike(s)
 	}
 
 	return s
 }
 
+var likeReplacer = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(s string) string {
+	return likeReplacer.Replace(s)
+}
+
+// arg converts filter value into query argument,
+// ignoreFilterErr is returned for values which should not be filtered.
+// Unsigned ints are converted into int64, floats into float64, slices into
+// slices of these plain types (see sliceArg).
+func (f filterExpr) arg(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, ignoreFilterErr
+	}
+	if val := reflect.ValueOf(v); val.Kind() == reflect.Ptr && val.IsNil() {
+		return nil, ignoreFilterErr
+	}
+
+	switch v := v.(type) {
+	case int, int32, int64, bool, string, float64, []byte:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case StringValue:
+		//if len(v.GetValue()) == 0 {
+		//	return "", nil, ignoreFilterErr
+		//}
+		return v.GetValue(), nil
+	case Int64Value:
+		return v.GetValue(), nil
+	case Int32Value:
+		return v.GetValue(), nil
+	case IntValue:
+		return v.GetValue(), nil
+	case BoolValue:
+		return v.GetValue(), nil
+	case UInt64Value:
+		return uintArg(v.GetValue())
+	case UInt32Value:
+		return int64(v.GetValue()), nil
+	case DoubleValue:
+		return v.GetValue(), nil
+	case FloatValue:
+		return float64(v.GetValue()), nil
+	case time.Time:
+		return v.UTC(), nil
+	case TimestampValue:
+		if v.GetSeconds() == 0 {
+			return nil, ignoreFilterErr
+		}
+		return time.Unix(v.GetSeconds(), 0).UTC(), nil
+
+	case protoreflect.Enum:
+		n := int32(v.Number())
+		if n == 0 {
+			// enum val with 0 must be UNSPECIFIED and should not be filtered
+			return nil, ignoreFilterErr
+		}
+		return n, nil
+	case pq.GenericArray:
+		return f.arg(v.A)
+	}
+
+	// named types, pointers (optional fields, *pq.Int64Array ...) and slices
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		return f.arg(rv.Elem().Interface())
+	case reflect.Int32:
+		return int32(rv.Int()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintArg(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Slice, reflect.Array:
+		return f.sliceArg(rv)
+	}
+
+	return nil, fmt.Errorf("unexpected type of rval in SQL filter: %T", v)
+}
+
+// wrappers of unsigned and float values
+type (
+	UInt64Value interface{ GetValue() uint64 }
+	UInt32Value interface{ GetValue() uint32 }
+	DoubleValue interface{ GetValue() float64 }
+	FloatValue  interface{ GetValue() float32 }
+)
+
+func uintArg(v uint64) (interface{}, error) {
+	if v > math.MaxInt64 {
+		return nil, fmt.Errorf("value %d is out of bigint range", v)
+	}
+	return int64(v), nil
+}
+
+// sliceArg converts slice items by arg, nil items and UNSPECIFIED enums are skipped.
+// Empty slice is ignored.
+func (f filterExpr) sliceArg(rv reflect.Value) (interface{}, error) {
+	switch v := rv.Interface().(type) {
+	case []int, []int32, []int64, []string, []bool, []float64:
+		if rv.Len() == 0 {
+			return nil, ignoreFilterErr
+		}
+		return v, nil
+	}
+
+	var ret reflect.Value
+	for i := 0; i < rv.Len(); i++ {
+		item, err := f.arg(rv.Index(i).Interface())
+		if err == ignoreFilterErr {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		iv := reflect.ValueOf(item)
+		if !ret.IsValid() {
+			if iv.Kind() == reflect.Slice && iv.Type().Elem().Kind() != reflect.Uint8 {
+				return nil, fmt.Errorf("unexpected type of rval in SQL filter: %s", rv.Type())
+			}
+			ret = reflect.MakeSlice(reflect.SliceOf(iv.Type()), 0, rv.Len())
+		}
+		if iv.Type() != ret.Type().Elem() {
+			return nil, fmt.Errorf("mixed item types in SQL filter slice: %s and %s", ret.Type().Elem(), iv.Type())
+		}
+		ret = reflect.Append(ret, iv)
+	}
+
+	if !ret.IsValid() {
+		return nil, ignoreFilterErr
+	}
+
+	return ret.Interface(), nil
+}
+
+// arrayType returns postgres type of array argument
+func arrayType(v interface{}) string {
+	switch v.(type) {
+	case []int, []int32:
+		return "integer"
+	case []int64:
+		return "bigint"
+	case []bool:
+		return "boolean"
+	case []float64:
+		return "double precision"
+	case []time.Time:
+		return "timestamptz"
+	case [][]byte:
+		return "bytea"
+	}
+
+	return "text"
+}
+
+type betweenVal struct {
+	from, to interface{}
+}
+
+// formatBetween falls back to single bound comparison if other bound is nil
+func (f filterExpr) formatBetween(gidx int) (string, []interface{}, error) {
+	r := f.rval.(betweenVal)
+
+	from, err := f.arg(r.from)
+	if err != nil && err != ignoreFilterErr {
+		return "", nil, err
+	}
+	to, err := f.arg(r.to)
+	if err != nil && err != ignoreFilterErr {
+		return "", nil, err
+	}
+
+	switch {
+	case from != nil && to != nil:
+		return fmt.Sprintf("%s BETWEEN $%d AND $%d", f.lval, gidx, gidx+1), []interface{}{from, to}, nil
+	case from != nil:
+		return fmt.Sprintf("%s >= $%d", f.lval, gidx), []interface{}{from}, nil
+	case to != nil:
+		return fmt.Sprintf("%s <= $%d", f.lval, gidx), []interface{}{to}, nil
+	}
+
+	return "", nil, ignoreFilterErr
+}
+
 func (f filterExpr) format(gidx int) (string, []interface{}, error) {
+	if f.op == invalidOp {
+		return "", nil, f.rval.(error)
+	}
+
 	if f.op == rawOp {
-		return f.lval, []interface{}{}, nil
+		return f.formatRaw(gidx)
 	}
 
 	if f.rval == nil {
@@ -128,101 +374,82 @@ func (f filterExpr) format(gidx int) (string, []interface{}, error) {
 	switch f.op {
 	case orOp:
 		stmt, args, err := f.rval.(*Filter).toQuery(gidx, "OR")
+		if err != nil {
+			return "", nil, err
+		}
 		if stmt == "" {
 			return "", nil, ignoreFilterErr
 		}
-		return fmt.Sprintf("(%s)", stmt), args, err
+		return fmt.Sprintf("(%s)", stmt), args, nil
+	case andOp:
+		stmt, args, err := f.rval.(*Filter).toQuery(gidx, "AND")
+		if err != nil {
+			return "", nil, err
+		}
+		if stmt == "" {
+			return "", nil, ignoreFilterErr
+		}
+		return fmt.Sprintf("(%s)", stmt), args, nil
 	case notOp:
 		stmt, args, err := f.rval.(*Filter).toQuery(gidx, "OR")
+		if err != nil {
+			return "", nil, err
+		}
 		if stmt == "" {
 			return "", nil, ignoreFilterErr
 		}
-		return fmt.Sprintf("NOT (%s)", stmt), args, err
+		return fmt.Sprintf("NOT (%s)", stmt), args, nil
 	case emptyStrOp, notEmptyStrOp:
 		return fmt.Sprintf("%s %s ''", f.lval, f.op.value()), nil, nil
+	case isNullOp, notNullOp:
+		return fmt.Sprintf("%s %s", f.lval, f.op.value()), nil, nil
 	case arrEmptyOp:
 		return fmt.Sprintf("COALESCE(array_length(%s, 1), 0) = 0", f.lval), nil, nil
 	case jsonArrEmptyOp:
 		return fmt.Sprintf("COALESCE(json_array_length((%s)::json), 0) = 0", f.lval), nil, nil
 	}
 
-	if val := reflect.ValueOf(f.rval); val.Kind() == reflect.Ptr && val.IsNil() {
-		return "", nil, ignoreFilterErr
+	switch f.op {
+	case betweenOp:
+		return f.formatBetween(gidx)
+	case matchOp:
+		return f.formatMatch(gidx)
+	case similarOp:
+		return f.formatSimilar(gidx)
+	case inQueryOp, existsOp, notExistsOp:
+		return f.formatSubQuery(gidx)
 	}
 
-	var retList []interface{}
-	switch v := f.rval.(type) {
-	case int, int32, int64, bool:
-		retList = append(retList, f.rval)
-	case string:
-		retList = append(retList, f.formatStr(v))
-	case StringValue:
-		//if len(v.GetValue()) == 0 {
-		//	return "", nil, ignoreFilterErr
-		//}
-		retList = append(retList, f.formatStr(v.GetValue()))
-	case Int64Value:
-		retList = append(retList, v.GetValue())
-	case Int32Value:
-		retList = append(retList, v.GetValue())
-	case IntValue:
-		retList = append(retList, v.GetValue())
-	case BoolValue:
-		retList = append(retList, v.GetValue())
-	case []int:
-		if len(v) > 0 {
-			retList = append(retList, v)
-		}
-	case []int32:
-		if len(v) > 0 {
-			retList = append(retList, v)
-		}
-	case []int64:
-		if len(v) > 0 {
-			retList = append(retList, v)
-		}
-	case []string:
-		if len(v) > 0 {
-			retList = append(retList, v)
-		}
-	case time.Time:
-		retList = append(retList, v.UTC())
-	case TimestampValue:
-		if v.GetSeconds() == 0 {
-			return "", nil, ignoreFilterErr
-		}
-		retList = append(retList, time.Unix(v.GetSeconds(), 0).UTC())
-
-	case protoreflect.Enum:
-		n := int32(v.Number())
-		if n == 0 {
-			// enum val with 0 must be UNSPECIFIED and should not be filtered
-			return "", nil, ignoreFilterErr
-		}
-		retList = append(retList, n)
-	default:
-		return "", nil, fmt.Errorf("unexpected type of rval in SQL filter: %T", f.rval)
+	arg, err := f.arg(f.rval)
+	if err != nil {
+		return "", nil, err
 	}
-
-	if len(retList) == 0 {
-		return "", nil, ignoreFilterErr
+	if s, ok := arg.(string); ok {
+		arg = f.formatStr(s)
 	}
+	retList := []interface{}{arg}
 
 	placeholders := fmt.Sprintf("$%d", gidx)
 	switch f.op {
 	case jsonContainOp:
 		f.lval = fmt.Sprintf("(%s)::jsonb", f.lval)
+	case jsonPathOp:
+		f.lval = fmt.Sprintf("(%s)::jsonb", f.lval)
+		placeholders = fmt.Sprintf("$%d::jsonpath", gidx)
+	case jsonMsgContainOp:
+		f.lval = fmt.Sprintf("(%s)::jsonb", f.lval)
+		placeholders = fmt.Sprintf("$%d::jsonb", gidx)
 	case inOp:
 		placeholders = fmt.Sprintf("ANY($%d)", gidx)
+	case notInOp:
+		placeholders = fmt.Sprintf("ALL($%d)", gidx)
+	case eqFoldOp:
+		f.lval = fmt.Sprintf("lower(%s)", f.lval)
+		placeholders = fmt.Sprintf("lower($%d)", gidx)
 	case jsonArrInOp:
 		placeholders = fmt.Sprintf("$%d::text[]", gidx)
 	case arrContainOp, arrOverlapOp:
-		arrType := "text"
-		switch f.rval.(type) {
-		case []int:
-			arrType = "integer"
-		}
-
+		arrType := arrayType(arg)
 		placeholders = fmt.Sprintf("$%d::%s[]", gidx, arrType)
 		f.lval = fmt.Sprintf("%s::%s[]", f.lval, arrType)
 	}
@@ -232,6 +459,31 @@ func (f filterExpr) format(gidx int) (string, []interface{}, error) {
 
 type Filter struct {
 	exprList []filterExpr
+
+	strict   bool // ignored expressions are errors (see Strict)
+	allowAll bool // Update/Delete may run without WHERE clause (see AllowAll)
+}
+
+// Strict makes query building fail with ErrIgnoredFilter if some expression
+// (including expressions of nested groups) is ignored because of nil or empty value
+func (f *Filter) Strict() *Filter {
+	f.strict = true
+	return f
+}
+
+// AllowAll allows Repo.Update and Repo.Delete to affect all rows if filter
+// has no effective conditions. Otherwise they fail with ErrUnfiltered.
+func (f *Filter) AllowAll() *Filter {
+	f.allowAll = true
+	return f
+}
+
+// unfiltered checks that empty where statement of Update/Delete is allowed
+func (f *Filter) unfiltered(stmt string) error {
+	if stmt != "" || (f != nil && f.allowAll) {
+		return nil
+	}
+	return ErrUnfiltered
 }
 
 func (f *Filter) addExpr(e filterExpr) {
@@ -257,6 +509,28 @@ func (f *Filter) In(lval string, rval interface{}) *Filter {
 	return f
 }
 
+// NotIn is opposite to In (col != ALL(values))
+func (f *Filter) NotIn(lval string, rval interface{}) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: notInOp, rval: shouldBeSlice(rval)})
+	return f
+}
+
+func (f *Filter) IsNull(lval string) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: isNullOp, rval: ""})
+	return f
+}
+
+func (f *Filter) NotNull(lval string) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: notNullOp, rval: ""})
+	return f
+}
+
+// Between adds inclusive range condition, nil bound is not checked
+func (f *Filter) Between(lval string, from, to interface{}) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: betweenOp, rval: betweenVal{from, to}})
+	return f
+}
+
 func (f *Filter) Gt(lval string, rval interface{}) *Filter {
 	f.addExpr(filterExpr{lval: lval, op: gtOp, rval: rval})
 	return f
@@ -296,6 +570,42 @@ func (f *Filter) Contain(lval string, rval interface{}) *Filter {
 	return f
 }
 
+// HasPrefix adds case-insensitive prefix match (LIKE special chars in rval are escaped)
+func (f *Filter) HasPrefix(lval string, rval interface{}) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: prefixOp, rval: rval})
+	return f
+}
+
+// HasSuffix adds case-insensitive suffix match (LIKE special chars in rval are escaped)
+func (f *Filter) HasSuffix(lval string, rval interface{}) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: suffixOp, rval: rval})
+	return f
+}
+
+// Like adds case-sensitive LIKE with pattern passed as is
+func (f *Filter) Like(lval string, pattern interface{}) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: likeOp, rval: pattern})
+	return f
+}
+
+// Regex adds case-sensitive POSIX regular expression match (~)
+func (f *Filter) Regex(lval string, pattern interface{}) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: regexOp, rval: pattern})
+	return f
+}
+
+// IRegex adds case-insensitive POSIX regular expression match (~*)
+func (f *Filter) IRegex(lval string, pattern interface{}) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: iregexOp, rval: pattern})
+	return f
+}
+
+// EqFold adds case-insensitive equality (lower(col) = lower(val))
+func (f *Filter) EqFold(lval string, rval interface{}) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: eqFoldOp, rval: rval})
+	return f
+}
+
 func (f *Filter) JsonArrIn(lval string, rval interface{}) *Filter {
 	f.addExpr(filterExpr{lval: lval, op: jsonArrInOp, rval: rval})
 	return f
@@ -345,7 +655,18 @@ func shouldBeSlice(val interface{}) interface{} {
 		}
 		return []int{int(n)}
 	default:
-		return val
+		arg, err := filterExpr{}.arg(val)
+		if err != nil {
+			// fails or is ignored on query building
+			return val
+		}
+		if rv := reflect.ValueOf(arg); rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+			return arg
+		}
+
+		ret := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(arg)), 1, 1)
+		ret.Index(0).Set(reflect.ValueOf(arg))
+		return ret.Interface()
 	}
 }
 
@@ -379,6 +700,12 @@ func (f *Filter) Or(orFilter *Filter) *Filter {
 	return f
 }
 
+// And adds group of expressions joined by AND (useful inside Or)
+func (f *Filter) And(andFilter *Filter) *Filter {
+	f.addExpr(filterExpr{op: andOp, rval: andFilter})
+	return f
+}
+
 func (f *Filter) Not(orFilter *Filter) *Filter {
 	f.addExpr(filterExpr{op: notOp, rval: orFilter})
 	return f
@@ -389,6 +716,38 @@ func (f *Filter) Raw(cond string) *Filter {
 	return f
 }
 
+// RawArgs adds raw condition with arguments referenced as $1, $2 ... in cond.
+// Placeholders are renumbered on query building, so cond can be combined with other expressions.
+// Arguments are passed as is (nil argument is NULL, not ignored).
+func (f *Filter) RawArgs(cond string, args ...interface{}) *Filter {
+	f.addExpr(filterExpr{lval: cond, op: rawOp, rval: args})
+	return f
+}
+
+var placeholderRe = regexp.MustCompile(`\$(\d+)`)
+
+func (f filterExpr) formatRaw(gidx int) (string, []interface{}, error) {
+	args, _ := f.rval.([]interface{})
+	if len(args) == 0 {
+		return f.lval, []interface{}{}, nil
+	}
+
+	var err error
+	stmt := placeholderRe.ReplaceAllStringFunc(f.lval, func(p string) string {
+		n, _ := strconv.Atoi(p[1:])
+		if n < 1 || n > len(args) {
+			err = fmt.Errorf("raw condition %q: no argument for placeholder %s", f.lval, p)
+			return p
+		}
+		return fmt.Sprintf("$%d", gidx+n-1)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return stmt, args, nil
+}
+
 func (f *Filter) WhereQuery() (string, []interface{}, error) {
 	stmt, args, err := f.toQuery(1, "AND")
 	if err != nil || stmt == "" {
@@ -410,8 +769,18 @@ func (f *Filter) toQuery(startIdx int, op string) (string, []interface{}, error)
 
 	i := startIdx
 	for _, e := range f.exprList {
+		// nil group is ignored as empty one
+		if sub, _ := e.rval.(*Filter); f.strict && e.op.isGroup() && sub != nil {
+			g := *sub
+			g.strict = true
+			e.rval = &g
+		}
+
 		v, l, err := e.format(i)
 		if err == ignoreFilterErr {
+			if f.strict {
+				return "", nil, fmt.Errorf("%w: %s", ErrIgnoredFilter, e.ignoredName())
+			}
 			continue
 		}
 		if err != nil {
@@ -428,3 +797,11 @@ func (f *Filter) toQuery(startIdx int, op string) (string, []interface{}, error)
 
 	return whereStmt, argsList, nil
 }
+
+// ignoredName describes ignored expression in strict mode errors
+func (e filterExpr) ignoredName() string {
+	if e.op.isGroup() {
+		return fmt.Sprintf("empty %s group", operatorNames[e.op])
+	}
+	return fmt.Sprintf("%s %s", e.lval, operatorNames[e.op])
+}
@@ -0,0 +1,573 @@
+package protosql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stable names of operators used in JSON representation of Filter
+var operatorNames = map[operator]string{
+	eqOp:           "eq",
+	neqOp:          "neq",
+	gtOp:           "gt",
+	gteOp:          "gte",
+	ltOp:           "lt",
+	lteOp:          "lte",
+	containOp:      "contain",
+	inOp:           "in",
+	jsonArrInOp:    "json_arr_in",
+	jsonContainOp:  "json_contain",
+	emptyStrOp:     "empty_str",
+	notEmptyStrOp:  "not_empty_str",
+	arrContainOp:   "arr_contain",
+	arrOverlapOp:   "arr_overlap",
+	arrEmptyOp:     "arr_empty",
+	jsonArrEmptyOp: "json_arr_empty",
+	isNullOp:       "is_null",
+	notNullOp:      "not_null",
+	notInOp:        "not_in",
+	betweenOp:      "between",
+	prefixOp:       "prefix",
+	suffixOp:       "suffix",
+	likeOp:         "like",
+	regexOp:        "regex",
+	iregexOp:       "iregex",
+	eqFoldOp:       "eq_fold",
+	matchOp:        "match",
+	similarOp:      "similar",
+	jsonPathOp:     "json_path",
+
+	jsonMsgContainOp: "json_contain_msg",
+	inQueryOp:        "in_query",
+	existsOp:         "exists",
+	notExistsOp:      "not_exists",
+	orOp:             "or",
+	andOp:            "and",
+	notOp:            "not",
+	rawOp:            "raw",
+}
+
+func operatorByName(name string) (operator, bool) {
+	for op, n := range operatorNames {
+		if n == name {
+			return op, true
+		}
+	}
+	return 0, false
+}
+
+func (o operator) isGroup() bool {
+	return o == orOp || o == andOp || o == notOp
+}
+
+// operators without value
+func (o operator) isUnary() bool {
+	switch o {
+	case emptyStrOp, notEmptyStrOp, arrEmptyOp, jsonArrEmptyOp, isNullOp, notNullOp, rawOp:
+		return true
+	}
+	return false
+}
+
+// JSON representation of filter expression:
+//
+//	[{"op":"eq","column":"name","value":{"type":"string","v":"abc"}},
+//	 {"op":"between","column":"count","value":{...},"to":{...}},
+//	 {"op":"match","column":"name","value":{...},"config":"english"},
+//	 {"op":"similar","column":"name","value":{...},"threshold":0.5,"word":true},
+//	 {"op":"or","group":[...],"strict":true}]
+//
+// Filter with Strict or AllowAll flags is encoded as object:
+//
+//	{"strict":true,"allow_all":true,"exprs":[...]}
+type filterJSON struct {
+	Strict   bool             `json:"strict,omitempty"`
+	AllowAll bool             `json:"allow_all,omitempty"`
+	Exprs    []filterExprJSON `json:"exprs"`
+}
+
+type filterExprJSON struct {
+	Op     string           `json:"op"`
+	Column string           `json:"column,omitempty"`
+	Value  *filterValueJSON `json:"value,omitempty"`
+	To     *filterValueJSON `json:"to,omitempty"`
+	Group  []filterExprJSON `json:"group,omitempty"`
+	Strict bool             `json:"strict,omitempty"` // strict group
+
+	// text search options of match operator
+	Config string `json:"config,omitempty"`
+	Vector bool   `json:"vector,omitempty"`
+
+	// options of similar operator
+	Threshold float64 `json:"threshold,omitempty"`
+	Word      bool    `json:"word,omitempty"`
+
+	// arguments of raw condition
+	Args []*filterValueJSON `json:"args,omitempty"`
+}
+
+type filterValueJSON struct {
+	Type string          `json:"type"`
+	V    json.RawMessage `json:"v"`
+}
+
+// MarshalJSON encodes normalized filter into stable JSON representation.
+// Strict filter with ignored expressions can't be encoded (ErrIgnoredFilter).
+func (f *Filter) MarshalJSON() ([]byte, error) {
+	if f.hasSubQuery() {
+		return nil, errors.New("filter: subquery can't be encoded")
+	}
+	if _, _, err := f.toQuery(1, "AND"); errors.Is(err, ErrIgnoredFilter) {
+		return nil, err
+	}
+
+	nf := f.Normalize()
+	exprs, err := nf.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	if exprs == nil {
+		exprs = []filterExprJSON{}
+	}
+
+	if nf.strict || nf.allowAll {
+		return json.Marshal(filterJSON{Strict: nf.strict, AllowAll: nf.allowAll, Exprs: exprs})
+	}
+
+	return json.Marshal(exprs)
+}
+
+// UnmarshalJSON decodes filter encoded by MarshalJSON
+func (f *Filter) UnmarshalJSON(data []byte) error {
+	var fj filterJSON
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(data, &fj); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(data, &fj.Exprs); err != nil {
+		return err
+	}
+
+	nf, err := filterFromJSON(fj.Exprs)
+	if err != nil {
+		return err
+	}
+	nf.strict, nf.allowAll = fj.Strict, fj.AllowAll
+	*f = *nf
+
+	return nil
+}
+
+func (f *Filter) toJSON() ([]filterExprJSON, error) {
+	var (
+		ret []filterExprJSON
+		err error
+	)
+	for _, e := range f.exprList {
+		ej := filterExprJSON{Op: operatorNames[e.op], Column: e.lval}
+
+		switch {
+		case e.op == invalidOp:
+			return nil, e.rval.(error)
+		case e.op == inQueryOp || e.op == existsOp || e.op == notExistsOp:
+			return nil, fmt.Errorf("filter %s: subquery can't be encoded", ej.Op)
+		case e.op == rawOp:
+			args, _ := e.rval.([]interface{})
+			for _, arg := range args {
+				v, err := encodeFilterValue(arg)
+				if err != nil {
+					return nil, fmt.Errorf("filter %s %s: %w", e.lval, ej.Op, err)
+				}
+				ej.Args = append(ej.Args, v)
+			}
+		case e.op.isGroup():
+			sub := e.rval.(*Filter)
+			group, err := sub.toJSON()
+			if err != nil {
+				return nil, err
+			}
+			ej.Group, ej.Strict = group, sub.strict
+		case e.op == betweenOp:
+			r := e.rval.(betweenVal)
+			if ej.Value, err = encodeFilterValue(r.from); err != nil {
+				return nil, fmt.Errorf("filter %s %s: %w", e.lval, ej.Op, err)
+			}
+			if ej.To, err = encodeFilterValue(r.to); err != nil {
+				return nil, fmt.Errorf("filter %s %s: %w", e.lval, ej.Op, err)
+			}
+		case e.op == matchOp:
+			m := e.rval.(matchVal)
+			ej.Config, ej.Vector = m.config, m.vector
+			if ej.Value, err = encodeFilterValue(m.query); err != nil {
+				return nil, fmt.Errorf("filter %s %s: %w", e.lval, ej.Op, err)
+			}
+		case e.op == similarOp:
+			s := e.rval.(similarVal)
+			ej.Threshold, ej.Word = s.threshold, s.word
+			if ej.Value, err = encodeFilterValue(s.term); err != nil {
+				return nil, fmt.Errorf("filter %s %s: %w", e.lval, ej.Op, err)
+			}
+		case !e.op.isUnary():
+			if ej.Value, err = encodeFilterValue(e.rval); err != nil {
+				return nil, fmt.Errorf("filter %s %s: %w", e.lval, ej.Op, err)
+			}
+		}
+
+		ret = append(ret, ej)
+	}
+
+	return ret, nil
+}
+
+func filterFromJSON(exprs []filterExprJSON) (*Filter, error) {
+	var err error
+
+	f := NewFilter()
+	for _, ej := range exprs {
+		op, ok := operatorByName(ej.Op)
+		if !ok {
+			return nil, fmt.Errorf("unknown filter operation %q", ej.Op)
+		}
+
+		e := filterExpr{lval: ej.Column, op: op}
+		switch {
+		case op.isGroup():
+			group, err := filterFromJSON(ej.Group)
+			if err != nil {
+				return nil, err
+			}
+			group.strict = ej.Strict
+			e.rval = group
+		case op == inQueryOp || op == existsOp || op == notExistsOp:
+			return nil, fmt.Errorf("filter %s: subquery can't be decoded", ej.Op)
+		case op == rawOp:
+			if len(ej.Args) == 0 {
+				break
+			}
+			args := make([]interface{}, len(ej.Args))
+			for i, a := range ej.Args {
+				if args[i], err = a.decode(); err != nil {
+					return nil, fmt.Errorf("filter %s %s: %w", ej.Column, ej.Op, err)
+				}
+			}
+			e.rval = args
+		case op == betweenOp:
+			var r betweenVal
+			if r.from, err = ej.Value.decode(); err != nil {
+				return nil, fmt.Errorf("filter %s %s: %w", ej.Column, ej.Op, err)
+			}
+			if r.to, err = ej.To.decode(); err != nil {
+				return nil, fmt.Errorf("filter %s %s: %w", ej.Column, ej.Op, err)
+			}
+			e.rval = r
+		case op == matchOp:
+			m := matchVal{config: ej.Config, vector: ej.Vector}
+			if m.query, err = ej.Value.decode(); err != nil {
+				return nil, fmt.Errorf("filter %s %s: %w", ej.Column, ej.Op, err)
+			}
+			e.rval = m
+		case op == similarOp:
+			s := similarVal{threshold: ej.Threshold, word: ej.Word}
+			if s.term, err = ej.Value.decode(); err != nil {
+				return nil, fmt.Errorf("filter %s %s: %w", ej.Column, ej.Op, err)
+			}
+			e.rval = s
+		case op.isUnary():
+			e.rval = ""
+		default:
+			if ej.Value == nil {
+				return nil, fmt.Errorf("filter %s %s: value is missed", ej.Column, ej.Op)
+			}
+			v, err := ej.Value.decode()
+			if err != nil {
+				return nil, fmt.Errorf("filter %s %s: %w", ej.Column, ej.Op, err)
+			}
+			e.rval = v
+		}
+
+		f.addExpr(e)
+	}
+
+	return f, nil
+}
+
+// nil value is encoded as nil (for between bounds)
+func encodeFilterValue(v interface{}) (*filterValueJSON, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	typ, err := filterValueType(v)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filterValueJSON{Type: typ, V: data}, nil
+}
+
+func filterValueType(v interface{}) (string, error) {
+	switch v.(type) {
+	case string:
+		return "string", nil
+	case bool:
+		return "bool", nil
+	case int:
+		return "int", nil
+	case int32:
+		return "int32", nil
+	case int64:
+		return "int64", nil
+	case float64:
+		return "float64", nil
+	case []byte:
+		return "bytes", nil
+	case time.Time:
+		return "time", nil
+	case []int:
+		return "[]int", nil
+	case []int32:
+		return "[]int32", nil
+	case []int64:
+		return "[]int64", nil
+	case []string:
+		return "[]string", nil
+	case []bool:
+		return "[]bool", nil
+	case []float64:
+		return "[]float64", nil
+	case []time.Time:
+		return "[]time", nil
+	case [][]byte:
+		return "[]bytes", nil
+	}
+
+	return "", fmt.Errorf("unsupported value type %T", v)
+}
+
+func (v *filterValueJSON) decode() (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	var dest interface{}
+	switch v.Type {
+	case "string":
+		dest = new(string)
+	case "bool":
+		dest = new(bool)
+	case "int":
+		dest = new(int)
+	case "int32":
+		dest = new(int32)
+	case "int64":
+		dest = new(int64)
+	case "float64":
+		dest = new(float64)
+	case "bytes":
+		dest = new([]byte)
+	case "time":
+		dest = new(time.Time)
+	case "[]int":
+		dest = new([]int)
+	case "[]int32":
+		dest = new([]int32)
+	case "[]int64":
+		dest = new([]int64)
+	case "[]string":
+		dest = new([]string)
+	case "[]bool":
+		dest = new([]bool)
+	case "[]float64":
+		dest = new([]float64)
+	case "[]time":
+		dest = new([]time.Time)
+	case "[]bytes":
+		dest = new([][]byte)
+	default:
+		return nil, fmt.Errorf("unsupported value type %q", v.Type)
+	}
+
+	if err := json.Unmarshal(v.V, dest); err != nil {
+		return nil, err
+	}
+
+	return reflect.ValueOf(dest).Elem().Interface(), nil
+}
+
+// Normalize returns equivalent filter in canonical form: wrapped values are unwrapped,
+// expressions ignored in SQL (nil values, UNSPECIFIED enums, empty lists) are removed,
+// nested groups of the same kind are flattened and expressions are sorted.
+// Strict and AllowAll flags are kept, ignored expressions of strict filter are kept too.
+func (f *Filter) Normalize() *Filter {
+	ret := f.normalize(andOp, false)
+	if f != nil {
+		ret.allowAll = f.allowAll
+	}
+	return ret
+}
+
+// inherited is strict mode of parent filter
+func (f *Filter) normalize(join operator, inherited bool) *Filter {
+	ret := NewFilter()
+	if f == nil {
+		return ret
+	}
+
+	strict := inherited || f.strict
+	ret.strict = f.strict && !inherited
+
+	for _, e := range f.exprList {
+		switch {
+		case e.op.isGroup():
+			inner := andOp
+			if e.op != andOp {
+				inner = orOp
+			}
+
+			group := e.rval.(*Filter).normalize(inner, strict)
+			switch {
+			case len(group.exprList) == 0 && !strict:
+				continue
+			case len(group.exprList) > 0 && !group.strict && e.op != notOp && (e.op == join || len(group.exprList) == 1):
+				ret.exprList = append(ret.exprList, group.exprList...)
+				continue
+			}
+			e.rval = group
+		case e.op == betweenOp:
+			r := e.rval.(betweenVal)
+			from, fromOk := normalizeValue(r.from)
+			to, toOk := normalizeValue(r.to)
+			switch {
+			case fromOk && toOk:
+				e.rval = betweenVal{from, to}
+			case fromOk:
+				e.op, e.rval = gteOp, from
+			case toOk:
+				e.op, e.rval = lteOp, to
+			case !strict:
+				continue
+			}
+		case e.op == matchOp:
+			m := e.rval.(matchVal)
+			query, err := m.queryArg()
+			if err == ignoreFilterErr && !strict {
+				continue
+			}
+			if err == nil {
+				m.query = query
+			}
+			e.rval = m
+		case e.op == similarOp:
+			s := e.rval.(similarVal)
+			term, err := s.termArg()
+			if err == ignoreFilterErr && !strict {
+				continue
+			}
+			if err == nil {
+				s.term = term
+			}
+			e.rval = s
+		case !e.op.isUnary():
+			v, ok := normalizeValue(e.rval)
+			if !ok && !strict {
+				continue
+			}
+			if ok {
+				e.rval = v
+			}
+		}
+
+		ret.addExpr(e)
+	}
+
+	sort.SliceStable(ret.exprList, func(i, j int) bool {
+		return ret.exprList[i].String() < ret.exprList[j].String()
+	})
+
+	return ret
+}
+
+// normalizeValue unwraps value the same way as filterExpr.format does,
+// false is returned for values ignored by filter
+func normalizeValue(v interface{}) (interface{}, bool) {
+	arg, err := filterExpr{}.arg(v)
+	switch err {
+	case nil:
+		return arg, true
+	case ignoreFilterErr:
+		return nil, false
+	}
+
+	// unsupported value is kept as is, it fails on query
+	return v, true
+}
+
+// Equal reports whether filters are equal after normalization
+func (f *Filter) Equal(other *Filter) bool {
+	return reflect.DeepEqual(f.Normalize(), other.Normalize())
+}
+
+// String renders filter as SQL condition with inline values (for logs and debugging),
+// subqueries are rendered as (...)
+func (f *Filter) String() string {
+	stmt, args, err := f.stubSubQueries().toQuery(1, "AND")
+	if err != nil {
+		return fmt.Sprintf("<invalid filter: %s>", err)
+	}
+
+	return inlineArgs(stmt, args)
+}
+
+func (e filterExpr) String() string {
+	return (&Filter{exprList: []filterExpr{e}}).String()
+}
+
+// inlineArgs replaces $N placeholders with SQL literals of args
+func inlineArgs(stmt string, args []interface{}) string {
+	// replace from the end, so $1 does not match prefix of $10
+	for i := len(args); i > 0; i-- {
+		stmt = strings.ReplaceAll(stmt, "$"+strconv.Itoa(i), sqlLiteral(args[i-1]))
+	}
+
+	return stmt
+}
+
+func sqlLiteral(v interface{}) string {
+	quote := func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+
+	switch val := v.(type) {
+	case string:
+		return quote(val)
+	case time.Time:
+		return quote(val.Format(time.RFC3339Nano))
+	case []byte:
+		return fmt.Sprintf("'\\x%x'::bytea", val)
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+		items := make([]string, rv.Len())
+		for i := range items {
+			item := rv.Index(i).Interface()
+			if s, ok := item.(string); ok {
+				item = strconv.Quote(s)
+			}
+			items[i] = fmt.Sprint(item)
+		}
+		return quote("{" + strings.Join(items, ",") + "}")
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,573 @@
+package protosql
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FilterSyntaxError is returned by ParseFilter for invalid expressions.
+// Pos is 1-based position of the problem in expression.
+type FilterSyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *FilterSyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter: %s at position %d", e.Msg, e.Pos)
+}
+
+// ParseFilter parses AIP-160 filter expression into Filter for repo model
+func (r *Repo) ParseFilter(expr string) (*Filter, error) {
+	return ParseFilter(expr, r.model)
+}
+
+// ParseFilter parses AIP-160 filter expression (https://google.aip.dev/160) into Filter.
+// Field names are validated against model, nested message fields (e.g. nested.name)
+// are mapped to json extraction of the column.
+// Supported: comparisons (= != < <= > >=), has operator (:), AND, OR, NOT, -, parentheses,
+// quoted strings, numbers, booleans, RFC3339 timestamps (quoted or not), durations ("20s") and enum names.
+func ParseFilter(expr string, model Model) (*Filter, error) {
+	p := &filterParser{lex: newFilterLexer(expr), model: model}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	f := NewFilter()
+	if p.tok.kind == tokEOF {
+		return f, nil
+	}
+
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+
+	if err := p.compile(node, f, "AND"); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// --- lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokText
+	tokString
+	tokComparator
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokMinus
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.val)
+	default:
+		return fmt.Sprintf("'%s'", t.val)
+	}
+}
+
+type filterLexer struct {
+	src []rune
+	pos int
+}
+
+func newFilterLexer(s string) *filterLexer {
+	return &filterLexer{src: []rune(s)}
+}
+
+func isTextRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-+*", r)
+}
+
+func (l *filterLexer) next() (token, error) {
+	for l.pos < len(l.src) && unicode.IsSpace(l.src[l.pos]) {
+		l.pos++
+	}
+
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start + 1}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, val: "(", pos: start + 1}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, val: ")", pos: start + 1}, nil
+	case c == '=' || c == ':':
+		l.pos++
+		return token{kind: tokComparator, val: string(c), pos: start + 1}, nil
+	case c == '<' || c == '>' || c == '!':
+		l.pos++
+		if l.pos < len(l.src) && l.src[l.pos] == '=' {
+			l.pos++
+			return token{kind: tokComparator, val: string(c) + "=", pos: start + 1}, nil
+		}
+		if c == '!' {
+			return token{}, &FilterSyntaxError{Pos: start + 1, Msg: "unexpected '!'"}
+		}
+		return token{kind: tokComparator, val: string(c), pos: start + 1}, nil
+	case c == '"' || c == '\'':
+		var sb strings.Builder
+		l.pos++
+		for l.pos < len(l.src) {
+			r := l.src[l.pos]
+			l.pos++
+			switch {
+			case r == c:
+				return token{kind: tokString, val: sb.String(), pos: start + 1}, nil
+			case r == '\\' && l.pos < len(l.src):
+				sb.WriteRune(l.src[l.pos])
+				l.pos++
+			default:
+				sb.WriteRune(r)
+			}
+		}
+		return token{}, &FilterSyntaxError{Pos: start + 1, Msg: "unterminated string"}
+	case c == '-' && l.pos+1 < len(l.src) && !unicode.IsDigit(l.src[l.pos+1]) && !unicode.IsSpace(l.src[l.pos+1]):
+		l.pos++
+		return token{kind: tokMinus, val: "-", pos: start + 1}, nil
+	case isTextRune(c):
+		// ':' between digits of value starting with digit is a part of unquoted
+		// timestamp (2021-01-02T03:04:05Z), field names can't start with digit
+		for l.pos < len(l.src) && (isTextRune(l.src[l.pos]) ||
+			unicode.IsDigit(c) && l.src[l.pos] == ':' && l.pos+1 < len(l.src) && unicode.IsDigit(l.src[l.pos+1])) {
+			l.pos++
+		}
+		val := string(l.src[start:l.pos])
+		kind := tokText
+		switch val {
+		case "AND":
+			kind = tokAnd
+		case "OR":
+			kind = tokOr
+		case "NOT":
+			kind = tokNot
+		}
+		return token{kind: kind, val: val, pos: start + 1}, nil
+	}
+
+	return token{}, &FilterSyntaxError{Pos: start + 1, Msg: fmt.Sprintf("unexpected character '%c'", c)}
+}
+
+// --- parser
+
+type filterNode struct {
+	op       string // AND, OR, NOT, CMP
+	children []*filterNode
+
+	field      token
+	comparator token
+	value      token
+}
+
+type filterParser struct {
+	lex   *filterLexer
+	tok   token
+	model Model
+}
+
+func (p *filterParser) next() error {
+	t, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = t
+	return nil
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return &FilterSyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// expression: sequence {AND sequence}
+func (p *filterParser) parseExpr() (*filterNode, error) {
+	node := &filterNode{op: "AND"}
+	for {
+		seq, err := p.parseSequence()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, seq)
+
+		if p.tok.kind != tokAnd {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	return simplify(node), nil
+}
+
+// sequence: factor {factor} (implicit AND)
+func (p *filterParser) parseSequence() (*filterNode, error) {
+	node := &filterNode{op: "AND"}
+	for {
+		factor, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, factor)
+
+		switch p.tok.kind {
+		case tokText, tokString, tokLParen, tokNot, tokMinus:
+			continue
+		}
+		break
+	}
+
+	return simplify(node), nil
+}
+
+// factor: term {OR term}
+func (p *filterParser) parseFactor() (*filterNode, error) {
+	node := &filterNode{op: "OR"}
+	for {
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, term)
+
+		if p.tok.kind != tokOr {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	return simplify(node), nil
+}
+
+// term: [NOT | -] simple
+func (p *filterParser) parseTerm() (*filterNode, error) {
+	if p.tok.kind == tokNot || p.tok.kind == tokMinus {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		child, err := p.parseSimple()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNode{op: "NOT", children: []*filterNode{child}}, nil
+	}
+
+	return p.parseSimple()
+}
+
+// simple: restriction | ( expression )
+func (p *filterParser) parseSimple() (*filterNode, error) {
+	switch p.tok.kind {
+	case tokLParen:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ')' but got %s", p.tok)
+		}
+		return node, p.next()
+	case tokText:
+	default:
+		return nil, p.errorf("expected field name but got %s", p.tok)
+	}
+
+	node := &filterNode{op: "CMP", field: p.tok}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokComparator {
+		return nil, p.errorf("expected comparator after '%s'", node.field.val)
+	}
+	node.comparator = p.tok
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokText && p.tok.kind != tokString {
+		return nil, p.errorf("expected value but got %s", p.tok)
+	}
+	node.value = p.tok
+
+	return node, p.next()
+}
+
+func simplify(n *filterNode) *filterNode {
+	if len(n.children) == 1 {
+		return n.children[0]
+	}
+	return n
+}
+
+// --- compiler
+
+// compile adds node expressions into filter f which joins them with join operator
+func (p *filterParser) compile(n *filterNode, f *Filter, join string) error {
+	switch n.op {
+	case "AND", "OR":
+		target := f
+		if n.op != join {
+			target = NewFilter()
+		}
+		for _, c := range n.children {
+			if err := p.compile(c, target, n.op); err != nil {
+				return err
+			}
+		}
+		if n.op != join {
+			if n.op == "AND" {
+				f.And(target)
+			} else {
+				f.Or(target)
+			}
+		}
+	case "NOT":
+		sub := NewFilter()
+		if err := p.compile(n.children[0], sub, "OR"); err != nil {
+			return err
+		}
+		f.Not(sub)
+	default:
+		return p.compileRestriction(n, f)
+	}
+
+	return nil
+}
+
+type filterField struct {
+	column string
+	typ    reflect.Type
+	nested jsonField // resolved path of nested field
+}
+
+func (p *filterParser) resolveField(t token) (filterField, error) {
+	jf, err := resolveJsonPath(p.model, t.val)
+	if err != nil {
+		return filterField{}, &FilterSyntaxError{Pos: t.pos, Msg: err.Error()}
+	}
+
+	column := jf.segments[0].key
+	if len(jf.segments) == 1 {
+		typ := jf.typ
+		if jf.segments[0].list {
+			typ = reflect.SliceOf(typ)
+		}
+		return filterField{column: column, typ: typ}, nil
+	}
+
+	// nested message stored as json
+	if !jf.isList() {
+		if column, err = jf.expr(); err != nil {
+			return filterField{}, &FilterSyntaxError{Pos: t.pos, Msg: fmt.Sprintf("field '%s' is not comparable", t.val)}
+		}
+	}
+
+	return filterField{column: column, typ: jf.typ, nested: jf}, nil
+}
+
+var comparatorOps = map[string]string{
+	"=":  FilterEq,
+	"!=": FilterNeq,
+	"<":  FilterLt,
+	"<=": FilterLte,
+	">":  FilterGt,
+	">=": FilterGte,
+}
+
+func (p *filterParser) compileRestriction(n *filterNode, f *Filter) error {
+	field, err := p.resolveField(n.field)
+	if err != nil {
+		return err
+	}
+
+	cmp := n.comparator.val
+	isList := field.typ.Kind() == reflect.Slice && field.typ.Elem().Kind() != reflect.Uint8
+	nested := len(field.nested.segments) > 1
+
+	if cmp == ":" && n.value.kind == tokText && n.value.val == "*" {
+		switch {
+		case nested:
+			// zero values are omitted in json
+			f.JsonPathExists(field.nested.segments[0].key, field.nested.jsonPath())
+		case isList:
+			f.Not(NewFilter().ArrEmpty(field.column))
+		case field.typ.Kind() == reflect.String:
+			f.NotEmptyStr(field.column)
+		default:
+			f.NotNull(field.column)
+		}
+		return nil
+	}
+
+	if field.typ.Kind() == reflect.Map || isMessageType(field.typ) ||
+		(isList && field.typ.Elem().Kind() == reflect.Ptr) {
+		return &FilterSyntaxError{Pos: n.field.pos, Msg: fmt.Sprintf("field '%s' is not comparable", n.field.val)}
+	}
+
+	if nested && field.nested.isList() {
+		v, err := filterArg(n.value, field.typ)
+		if err != nil {
+			return err
+		}
+
+		op, ok := comparatorOps[cmp]
+		if cmp == ":" {
+			op, ok = FilterEq, true
+			if field.typ.Kind() == reflect.String {
+				op = FilterContains
+			}
+		}
+		if !ok {
+			return &FilterSyntaxError{Pos: n.comparator.pos, Msg: fmt.Sprintf("unsupported operator '%s'", cmp)}
+		}
+
+		if err := f.addJsonPathRule(field.nested, op, v); err != nil {
+			return &FilterSyntaxError{Pos: n.field.pos, Msg: err.Error()}
+		}
+		return nil
+	}
+
+	if isList {
+		if cmp != ":" {
+			return &FilterSyntaxError{Pos: n.comparator.pos, Msg: fmt.Sprintf("repeated field '%s' supports only ':' operator", n.field.val)}
+		}
+
+		v, err := filterArg(n.value, field.typ.Elem())
+		if err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(v)), 1, 1)
+		slice.Index(0).Set(reflect.ValueOf(v))
+		f.ArrContain(field.column, slice.Interface())
+		return nil
+	}
+
+	v, err := filterArg(n.value, field.typ)
+	if err != nil {
+		return err
+	}
+
+	switch cmp {
+	case "=":
+		f.Eq(field.column, v)
+	case "!=":
+		f.Neq(field.column, v)
+	case "<":
+		f.Lt(field.column, v)
+	case "<=":
+		f.Lte(field.column, v)
+	case ">":
+		f.Gt(field.column, v)
+	case ">=":
+		f.Gte(field.column, v)
+	case ":":
+		if field.typ.Kind() != reflect.String {
+			return &FilterSyntaxError{Pos: n.comparator.pos, Msg: fmt.Sprintf("':' operator is not supported for field '%s'", n.field.val)}
+		}
+		f.Contain(field.column, v)
+	}
+
+	return nil
+}
+
+var enumType = reflect.TypeOf((*protoreflect.Enum)(nil)).Elem()
+
+// filterArg converts literal into value of field type
+func filterArg(t token, typ reflect.Type) (interface{}, error) {
+	errf := func(format string, args ...interface{}) error {
+		return &FilterSyntaxError{Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	switch {
+	case typ.Implements(timeIfaceType):
+		ts, err := time.Parse(time.RFC3339Nano, t.val)
+		if err != nil {
+			return nil, errf("invalid timestamp %s, RFC3339 is expected", t)
+		}
+		return ts, nil
+	case typ.Implements(durationIfaceType):
+		d, err := time.ParseDuration(t.val)
+		if err != nil {
+			return nil, errf("invalid duration %s", t)
+		}
+		// durations are stored in milliseconds
+		return d.Milliseconds(), nil
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return t.val, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(t.val)
+		if err != nil {
+			return nil, errf("invalid boolean %s", t)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, err := strconv.ParseInt(t.val, 10, 64); err == nil {
+			return n, nil
+		}
+		if typ.Implements(enumType) {
+			e := reflect.Zero(typ).Interface().(protoreflect.Enum)
+			if ev := e.Descriptor().Values().ByName(protoreflect.Name(t.val)); ev != nil {
+				return int32(ev.Number()), nil
+			}
+			return nil, errf("unknown value %s of enum %s", t, e.Descriptor().Name())
+		}
+		return nil, errf("invalid integer %s", t)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(t.val, 10, 64)
+		if err != nil {
+			return nil, errf("invalid unsigned integer %s", t)
+		}
+		v, err := uintArg(n)
+		if err != nil {
+			return nil, errf("%s", err)
+		}
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, errf("invalid number %s", t)
+		}
+		return n, nil
+	}
+
+	return nil, errf("unsupported value %s", t)
+}
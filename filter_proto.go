@@ -0,0 +1,152 @@
+package protosql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fabregas/protosql/options"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Filter operations supported by FilterFromProto
+const (
+	FilterEq         = "eq"
+	FilterNeq        = "neq"
+	FilterGt         = "gt"
+	FilterGte        = "gte"
+	FilterLt         = "lt"
+	FilterLte        = "lte"
+	FilterIn         = "in"
+	FilterContains   = "contains"
+	FilterArrContain = "has"
+	FilterArrOverlap = "overlap"
+)
+
+// field name suffixes recognized by convention (e.g. create_time_gte)
+var filterSuffixes = []string{
+	FilterNeq, FilterGte, FilterLte, FilterGt, FilterLt, FilterIn,
+	FilterContains, FilterArrContain, FilterArrOverlap, FilterEq,
+}
+
+// FilterRule maps filter message field to column and operation.
+// Column "-" means that field should be skipped.
+type FilterRule struct {
+	Column string
+	Op     string
+}
+
+// FilterMapping overrides conventions for filter message fields (key is proto field name)
+type FilterMapping map[string]FilterRule
+
+// FilterFromProto builds Filter from request filter message.
+// By convention field <column>_<op> is mapped to operation op on column,
+// field without known suffix is mapped to equality.
+// Convention is overridden by filter of protosql.field option and then by mapping.
+// Fields with presence (optional, messages) are used if they are set, so optional field
+// can filter by zero value (e.g. active = false). Other fields are ignored if they are
+// empty (zero, nil, UNSPECIFIED).
+func FilterFromProto(msg Model, mapping FilterMapping) (*Filter, error) {
+	f := NewFilter()
+
+	var pm protoreflect.Message
+	if m, ok := msg.(protoreflect.ProtoMessage); ok {
+		pm = m.ProtoReflect()
+	}
+
+	for _, field := range parseProtoMsg(msg) {
+		var fd protoreflect.FieldDescriptor
+		if pm != nil {
+			fd = pm.Descriptor().Fields().ByName(protoreflect.Name(field.name))
+		}
+
+		rule, ok := mapping[field.name]
+		if !ok {
+			rule = ruleByOption(fd, ruleByConvention(field.name))
+		}
+		if rule.Column == "-" {
+			continue
+		}
+		if rule.Op == "" {
+			rule.Op = FilterEq
+		}
+
+		set := !field.val.IsZero()
+		if fd != nil && fd.HasPresence() {
+			set = pm.Has(fd)
+		}
+		if !set {
+			continue
+		}
+
+		if err := f.addRule(rule, field.val.Interface()); err != nil {
+			return nil, fmt.Errorf("filter field %s: %w", field.name, err)
+		}
+	}
+
+	return f, nil
+}
+
+func ruleByConvention(name string) FilterRule {
+	for _, op := range filterSuffixes {
+		if col := strings.TrimSuffix(name, "_"+op); col != name && col != "" {
+			return FilterRule{Column: col, Op: op}
+		}
+	}
+
+	return FilterRule{Column: name, Op: FilterEq}
+}
+
+// ruleByOption applies filter of protosql.field option to rule
+func ruleByOption(fd protoreflect.FieldDescriptor, rule FilterRule) FilterRule {
+	if fd == nil {
+		return rule
+	}
+
+	f, ok := proto.GetExtension(fd.Options(), options.E_Field).(*options.Field)
+	if !ok || f.GetFilter() == nil {
+		return rule
+	}
+	opt := f.Filter
+
+	if opt.Skip {
+		return FilterRule{Column: "-"}
+	}
+	if opt.Column != "" {
+		rule.Column = opt.Column
+	}
+	if opt.Op != "" {
+		rule.Op = opt.Op
+	}
+
+	return rule
+}
+
+func (f *Filter) addRule(rule FilterRule, val interface{}) error {
+	switch rule.Op {
+	case FilterEq:
+		f.Eq(rule.Column, val)
+	case FilterNeq:
+		f.Neq(rule.Column, val)
+	case FilterGt:
+		f.Gt(rule.Column, val)
+	case FilterGte:
+		f.Gte(rule.Column, val)
+	case FilterLt:
+		f.Lt(rule.Column, val)
+	case FilterLte:
+		f.Lte(rule.Column, val)
+	case FilterIn:
+		f.In(rule.Column, val)
+	case FilterContains:
+		f.Contain(rule.Column, val)
+	case FilterArrContain:
+		f.ArrContain(rule.Column, val)
+	case FilterArrOverlap:
+		f.ArrOverlap(rule.Column, val)
+	default:
+		return fmt.Errorf("unknown filter operation %q", rule.Op)
+	}
+
+	return nil
+}
@@ -0,0 +1,578 @@
+package protosql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fabregas/protosql/internal/testpb"
+	"github.com/lib/pq"
+
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type testListFilter struct {
+	Name          *sval                  `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	NameContains  string                 `protobuf:"bytes,2,opt,name=name_contains,proto3" json:"name_contains,omitempty"`
+	CreateTimeGte *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=create_time_gte,proto3" json:"create_time_gte,omitempty"`
+	CountLt       int64                  `protobuf:"varint,4,opt,name=count_lt,proto3" json:"count_lt,omitempty"`
+	IdIn          []int32                `protobuf:"varint,5,rep,name=id_in,proto3" json:"id_in,omitempty"`
+	Search        string                 `protobuf:"bytes,6,opt,name=search,proto3" json:"search,omitempty"`
+}
+
+func (*testListFilter) Reset()        {}
+func (*testListFilter) ProtoMessage() {}
+
+func TestFilterFromProto(t *testing.T) {
+	ts := timestamppb.Now()
+	msg := &testListFilter{
+		NameContains:  "abc",
+		CreateTimeGte: ts,
+		IdIn:          []int32{1, 2},
+		Search:        "descr",
+	}
+
+	f, err := FilterFromProto(msg, FilterMapping{"search": {Column: "description", Op: FilterContains}})
+	if err != nil {
+		t.Fatalf("FilterFromProto() failed: %s", err)
+	}
+
+	stmt, args, err := f.toQuery(1, "AND")
+	if err != nil {
+		t.Fatalf("toQuery() failed: %s", err)
+	}
+	expectEq(t, stmt, "name ILIKE $1 AND create_time >= $2 AND id = ANY($3) AND description ILIKE $4")
+	expectEq(t, args, []interface{}{"%abc%", ts.AsTime().Truncate(1e9).UTC(), []int32{1, 2}, "%descr%"})
+
+	_, err = FilterFromProto(msg, FilterMapping{"search": {Column: "description", Op: "like"}})
+	if err == nil {
+		t.Error("FilterFromProto() should fail on unknown operation")
+	}
+
+	// filter of protosql.field options
+	pmsg := &testpb.AccountFilter{NameContains: "abc", TenantId: "t1", Emails: []string{"a@b.c"}, LoginPrefix: "x", IdGt: 5}
+	f, err = FilterFromProto(pmsg, FilterMapping{"id_gt": {Column: "id", Op: FilterGt}})
+	if err != nil {
+		t.Fatalf("FilterFromProto() failed: %s", err)
+	}
+	stmt, args, err = f.toQuery(1, "AND")
+	if err != nil {
+		t.Fatalf("toQuery() failed: %s", err)
+	}
+	expectEq(t, stmt, "name ILIKE $1 AND tenant = $2 AND email = ANY($3) AND id > $4")
+	expectEq(t, args, []interface{}{"%abc%", "t1", []string{"a@b.c"}, int64(5)})
+
+	f, err = FilterFromProto(pmsg, nil)
+	if err != nil {
+		t.Fatalf("FilterFromProto() failed: %s", err)
+	}
+	stmt, _, _ = f.toQuery(1, "AND")
+	expectEq(t, stmt, "name ILIKE $1 AND tenant = $2 AND email = ANY($3) AND id >= $4")
+
+	// optional field filters by zero value
+	active := false
+	f, err = FilterFromProto(&testpb.AccountFilter{Active: &active}, nil)
+	if err != nil {
+		t.Fatalf("FilterFromProto() failed: %s", err)
+	}
+	stmt, args, _ = f.toQuery(1, "AND")
+	expectEq(t, stmt, "active = $1")
+	expectEq(t, args, []interface{}{false})
+
+	f, _ = FilterFromProto(&testpb.AccountFilter{}, nil)
+	expectEq(t, f.String(), "")
+}
+
+func TestParseFilter(t *testing.T) {
+	cases := []struct {
+		expr string
+		stmt string
+		args []interface{}
+	}{
+		{``, ``, nil},
+		{`name = "abc" count > 10`, `name = $1 AND count > $2`, []interface{}{"abc", int64(10)}},
+		{`status = 1 OR status = 2 AND NOT id = 5`, `(status = $1 OR status = $2) AND NOT (id = $3)`, []interface{}{int64(1), int64(2), int64(5)}},
+		{`(name:foo AND count <= 3) OR -description:*`, `((name ILIKE $1 AND count <= $2) OR NOT (description != ''))`, []interface{}{"%foo%", int64(3)}},
+		{`nested.num >= 2 nested.name = 'x y'`, `COALESCE((nested->>'num')::bigint, 0) >= $1 AND COALESCE(nested->>'name', '') = $2`, []interface{}{int64(2), "x y"}},
+		{`nested_list.name:foo nested_list.num:*`, `(nested_list)::jsonb @? $1::jsonpath AND (nested_list)::jsonb @? $2::jsonpath`, []interface{}{`$[*]."name" ? (@ like_regex "foo" flag "iq")`, `$[*]."num"`}},
+		{`tags:red online_duration < 1m30s`, `tags::text[] @> $1::text[] AND online_duration < $2`, []interface{}{[]string{"red"}, int64(90000)}},
+		{`create_time > "2021-01-02T03:04:05Z" tags:*`, `create_time > $1 AND NOT (COALESCE(array_length(tags, 1), 0) = 0)`, []interface{}{time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)}},
+		{`create_time > 2021-01-02T03:04:05.5Z count:*`, `create_time > $1 AND count IS NOT NULL`, []interface{}{time.Date(2021, 1, 2, 3, 4, 5, 5e8, time.UTC)}},
+	}
+
+	for _, c := range cases {
+		f, err := ParseFilter(c.expr, &TestModel{})
+		if err != nil {
+			t.Fatalf("ParseFilter(%s) failed: %s", c.expr, err)
+		}
+
+		stmt, args, err := f.toQuery(1, "AND")
+		if err != nil {
+			t.Fatalf("toQuery(%s) failed: %s", c.expr, err)
+		}
+		expectEq(t, stmt, c.stmt)
+		expectEq(t, args, c.args)
+	}
+
+	errCases := []struct {
+		expr string
+		err  string
+	}{
+		{`name = "abc`, `invalid filter: unterminated string at position 8`},
+		{`unknown = 1`, `invalid filter: unknown field 'unknown' at position 1`},
+		{`nested.foo = 1`, `invalid filter: unknown field 'nested.foo' at position 1`},
+		{`count = abc`, `invalid filter: invalid integer 'abc' at position 9`},
+		{`(name = a`, `invalid filter: expected ')' but got end of expression at position 10`},
+		{`name`, `invalid filter: expected comparator after 'name' at position 5`},
+		{`create_time > yesterday`, `invalid filter: invalid timestamp 'yesterday', RFC3339 is expected at position 15`},
+		{`tags = a`, `invalid filter: repeated field 'tags' supports only ':' operator at position 6`},
+	}
+
+	for _, c := range errCases {
+		_, err := ParseFilter(c.expr, &TestModel{})
+		if err == nil {
+			t.Fatalf("ParseFilter(%s) should fail", c.expr)
+		}
+		expectEq(t, err.Error(), c.err)
+	}
+
+	f, err := ParseFilter(`u64 = 9223372036854775807`, &testUintModel{})
+	if err != nil {
+		t.Fatalf("ParseFilter() failed: %s", err)
+	}
+	expectEq(t, f.String(), "u64 = 9223372036854775807")
+
+	_, err = ParseFilter(`u64 = 18446744073709551615`, &testUintModel{})
+	if err == nil {
+		t.Fatal("ParseFilter() should fail on uint64 out of bigint range")
+	}
+	expectEq(t, err.Error(), `invalid filter: value 18446744073709551615 is out of bigint range at position 7`)
+}
+
+type testUintModel struct {
+	U64 uint64 `protobuf:"varint,1,opt,name=u64,proto3" json:"u64,omitempty"`
+}
+
+func (*testUintModel) Reset()        {}
+func (*testUintModel) ProtoMessage() {}
+
+func TestFilterCodec(t *testing.T) {
+	ts := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := NewFilter().
+		Eq("name", WrapString("it's")).
+		Eq("website", WrapNotEmptyString("")).
+		Or(NewFilter().Gte("create_time", timestamppb.New(ts)).In("id", []int64{1, 2})).
+		Not(NewFilter().ArrContain("tags", "red")).
+		And(NewFilter().Lt("count", 10))
+
+	expectEq(t, NewFilter().Eq("data", []byte{0xde, 0xad}).String(), `data = '\xdead'::bytea`)
+	expectEq(t, f.String(), `name = 'it''s' AND (create_time >= '2021-01-02T03:04:05Z' OR id = ANY('{1,2}')) AND NOT (tags::text[] @> '{"red"}'::text[]) AND (count < 10)`)
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %s", err)
+	}
+
+	decoded := NewFilter()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %s", err)
+	}
+	if !decoded.Equal(f) {
+		t.Errorf("decoded filter %s != %s", decoded, f)
+	}
+
+	same := NewFilter().Lt("count", 10).Eq("name", "it's").
+		Not(NewFilter().ArrContain("tags", []string{"red"})).
+		Or(NewFilter().In("id", []int64{1, 2}).Gte("create_time", ts))
+	if !same.Equal(f) {
+		t.Errorf("filter %s should be equal to %s", same, f)
+	}
+	if NewFilter().Eq("name", "x").Equal(f) {
+		t.Error("filters should not be equal")
+	}
+
+	if err := json.Unmarshal([]byte(`[{"op":"like","column":"x"}]`), decoded); err == nil {
+		t.Error("json.Unmarshal() should fail on unknown operation")
+	}
+
+	// strict and allow all flags
+	var name *string
+	for _, f := range []*Filter{
+		NewFilter().AllowAll(),
+		NewFilter().Eq("id", 1).Strict(),
+		NewFilter().Eq("id", 1).Or(NewFilter().Eq("name", "x").Eq("website", "y").Strict()),
+	} {
+		data, err := json.Marshal(f)
+		if err != nil {
+			t.Fatalf("json.Marshal() failed: %s", err)
+		}
+		decoded := NewFilter()
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatalf("json.Unmarshal() failed: %s", err)
+		}
+		if !decoded.Equal(f) {
+			t.Errorf("decoded filter %s != %s", data, f)
+		}
+		expectEq(t, decoded.strict, f.strict)
+		expectEq(t, decoded.allowAll, f.allowAll)
+	}
+
+	data, err = json.Marshal(NewFilter().AllowAll())
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %s", err)
+	}
+	expectEq(t, string(data), `{"allow_all":true,"exprs":[]}`)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %s", err)
+	}
+	if err := decoded.unfiltered(""); err != nil {
+		t.Errorf("decoded filter should allow all: %s", err)
+	}
+
+	if NewFilter().Eq("id", 1).Strict().Equal(NewFilter().Eq("id", 1)) {
+		t.Error("strict filter should not be equal to lenient one")
+	}
+	if NewFilter().Eq("id", 1).Eq("name", name).Strict().Equal(NewFilter().Eq("id", 1).Strict()) {
+		t.Error("ignored expressions of strict filter should be kept")
+	}
+	if _, err := json.Marshal(NewFilter().Eq("name", name).Strict()); !errors.Is(err, ErrIgnoredFilter) {
+		t.Errorf("json.Marshal() of strict filter with ignored expression: %v", err)
+	}
+}
+
+func TestFilterOperators(t *testing.T) {
+	f := NewFilter().
+		IsNull("deleted_at").
+		NotIn("id", []int{1, 2}).
+		Between("count", 1, 10).
+		Or(NewFilter().HasPrefix("name", "a_b").HasSuffix("name", "%z").Like("website", "%.com")).
+		Not(NewFilter().Regex("description", "^x").IRegex("description", "y$").NotNull("parent_id")).
+		EqFold("name", "ABC").
+		Between("create_time", nil, WrapNotEmptyString("")).
+		Between("update_time", nil, int64(5)).
+		NotIn("status", nil)
+
+	stmt, args, err := f.toQuery(1, "AND")
+	if err != nil {
+		t.Fatalf("toQuery() failed: %s", err)
+	}
+	expectEq(t, stmt, `deleted_at IS NULL AND id != ALL($1) AND count BETWEEN $2 AND $3 AND `+
+		`(name ILIKE $4 OR name ILIKE $5 OR website LIKE $6) AND `+
+		`NOT (description ~ $7 OR description ~* $8 OR parent_id IS NOT NULL) AND `+
+		`lower(name) = lower($9) AND update_time <= $10`)
+	expectEq(t, args, []interface{}{[]int{1, 2}, 1, 10, `a\_b%`, `%\%z`, "%.com", "^x", "y$", "ABC", int64(5)})
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %s", err)
+	}
+	decoded := NewFilter()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %s", err)
+	}
+	if !decoded.Equal(f) {
+		t.Errorf("decoded filter %s != %s", decoded, f)
+	}
+}
+
+func TestFullTextSearch(t *testing.T) {
+	r := NewRepo(nil, "xxx_table", &TestModel{}, dummyLogger{}, WithSearchConfig("english"))
+
+	q := r.Select(context.Background()).
+		Where(NewFilter().Eq("status", 1).Or(NewFilter().Match("name", "foo bar", "").MatchVector("search_vector", "foo", "simple"))).
+		OrderBy(RankBy("description", "foo bar", "")).
+		Headline("description", "description", "foo bar", "")
+
+	stmt, args, err := q.buildQ(1, "", nil)
+	if err != nil {
+		t.Fatalf("buildQ() failed: %s", err)
+	}
+	expectEq(t, stmt, "SELECT xxx_table.id,xxx_table.name,xxx_table.website,"+
+		"ts_headline('english', xxx_table.description, websearch_to_tsquery('english', $5)) AS description,"+
+		"xxx_table.status,xxx_table.create_time,xxx_table.update_time,xxx_table.online_duration,xxx_table.count,"+
+		"xxx_table.nested,xxx_table.tags,xxx_table.nested_list,xxx_table.blob,xxx_table.old_statuses FROM xxx_table "+
+		" WHERE status = $1 AND (to_tsvector('english', name) @@ websearch_to_tsquery('english', $2) OR "+
+		"search_vector @@ websearch_to_tsquery('simple', $3)) "+
+		" ORDER BY ts_rank(to_tsvector('english', description), websearch_to_tsquery('english', $4)) DESC")
+	expectEq(t, args, []interface{}{1, "foo bar", "foo", "foo bar", "foo bar"})
+
+	empty := NewFilter().Match("name", "", "english")
+	expectEq(t, empty.String(), "")
+
+	if _, _, err := NewFilter().Match("name", "x", "english'--").toQuery(1, "AND"); err == nil {
+		t.Error("toQuery() should fail on invalid search config")
+	}
+
+	if _, _, err := r.Select(context.Background()).OrderBy(RankBy("name", 123, "")).buildQ(1, "", nil); err == nil {
+		t.Error("buildQ() should fail on non-string rank query")
+	}
+	if _, _, err := r.Select(context.Background()).OrderBy(RankBy("name", "x", "english'--")).buildQ(1, "", nil); err == nil {
+		t.Error("buildQ() should fail on invalid rank config")
+	}
+	if _, _, err := r.Select(context.Background()).Headline("name", "name", 123, "").buildQ(1, "", nil); err == nil {
+		t.Error("buildQ() should fail on non-string headline query")
+	}
+	if _, _, err := r.Select(context.Background()).Headline("name", "name", "x", "english'--").buildQ(1, "", nil); err == nil {
+		t.Error("buildQ() should fail on invalid headline config")
+	}
+
+	invalid := NewRepo(nil, "xxx_table", &TestModel{}, dummyLogger{}, WithSearchConfig("english'--"))
+	if _, _, err := invalid.Select(context.Background()).Where(NewFilter().Match("name", "x", "")).buildQ(1, "", nil); err == nil {
+		t.Error("buildQ() should fail on invalid repo search config")
+	}
+	if _, _, err := invalid.Select(context.Background()).OrderBy(RankBy("name", "x", "")).buildQ(1, "", nil); err == nil {
+		t.Error("buildQ() should fail on invalid repo search config of rank")
+	}
+	if _, _, err := invalid.Select(context.Background()).Where(NewFilter().Match("name", "x", "simple")).buildQ(1, "", nil); err != nil {
+		t.Errorf("buildQ() with explicit config failed: %s", err)
+	}
+}
+
+func TestSimilarSearch(t *testing.T) {
+	withDBMock(t, func(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+		r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+		q := r.Select(context.Background()).
+			Where(NewFilter().Eq("status", 1).Similar("name", "jonh", 0).WordSimilar("description", "smth", 0.4)).
+			OrderBy(SimilarityBy("name", "jonh"))
+
+		mock.ExpectQuery("^SELECT EXISTS").WithArgs("pg_trgm").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		stmt, args, err := q.buildQ(1, "", nil)
+		if err != nil {
+			t.Fatalf("buildQ() failed: %s", err)
+		}
+		expectEq(t, stmt[strings.Index(stmt, "WHERE"):], "WHERE status = $1 AND name % $2 AND word_similarity($3, description) >= 0.4  ORDER BY name <-> $4")
+		expectEq(t, args, []interface{}{1, "jonh", "smth", "jonh"})
+
+		// extension check is cached
+		if _, _, err := q.buildQ(1, "", nil); err != nil {
+			t.Fatalf("buildQ() failed: %s", err)
+		}
+
+		r = NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+		q.r = r
+		mock.ExpectQuery("^SELECT EXISTS").WithArgs("pg_trgm").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		stmt, args, err = q.buildQ(1, "", nil)
+		if err != nil {
+			t.Fatalf("buildQ() failed: %s", err)
+		}
+		expectEq(t, stmt[strings.Index(stmt, "WHERE"):], "WHERE status = $1 AND name ILIKE $2 AND description ILIKE $3  ORDER BY name ILIKE $4 DESC")
+		expectEq(t, args, []interface{}{1, "%jonh%", "%smth%", "jonh%"})
+
+		// missing extension is cached for a while, then checked again
+		if _, _, err := q.buildQ(1, "", nil); err != nil {
+			t.Fatalf("buildQ() failed: %s", err)
+		}
+		r.extensions["pg_trgm"] = extension{checked: time.Now().Add(-extensionRecheck)}
+		mock.ExpectQuery("^SELECT EXISTS").WithArgs("pg_trgm").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		stmt, _, err = q.buildQ(1, "", nil)
+		if err != nil {
+			t.Fatalf("buildQ() failed: %s", err)
+		}
+		expectEq(t, stmt[strings.Index(stmt, "WHERE"):], "WHERE status = $1 AND name % $2 AND word_similarity($3, description) >= 0.4  ORDER BY name <-> $4")
+
+		if _, _, err := r.Select(context.Background()).OrderBy(SimilarityBy("name", 123)).buildQ(1, "", nil); err == nil {
+			t.Error("buildQ() should fail on non-string similarity term")
+		}
+
+		expectEq(t, searchRuleQuery(SearchRule{SimilarTo: "u.name"}, queryOpts{trgm: true}), "u.name % $2")
+		expectEq(t, searchRuleQuery(SearchRule{Query: "u.id = $2", SimilarTo: "u.name"}, queryOpts{}), "(u.id = $2 OR u.name ILIKE $1)")
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestFilterPath(t *testing.T) {
+	m := &TestModel{}
+	f := NewFilter().
+		Path(m, "nested.active", FilterEq, true).
+		Path(m, "nested_list.num", FilterIn, []int{1, 2}).
+		Path(m, "nested_list.name", FilterNeq, "x\"y").
+		Path(m, "nested_list.num", FilterGt, nil).
+		Path(m, "name", FilterContains, "abc").
+		Path(m, "tags", FilterArrContain, "red").
+		JsonContainsMsg("nested", &NestedModel{Name: "abc"})
+
+	stmt, args, err := f.toQuery(1, "AND")
+	if err != nil {
+		t.Fatalf("toQuery() failed: %s", err)
+	}
+	expectEq(t, stmt, `COALESCE((nested->>'active')::boolean, false) = $1 AND (nested_list)::jsonb @? $2::jsonpath AND `+
+		`(nested_list)::jsonb @? $3::jsonpath AND name ILIKE $4 AND tags::text[] @> $5::text[] AND (nested)::jsonb @> $6::jsonb`)
+	expectEq(t, args, []interface{}{true, `$[*]."num" ? (@ == 1 || @ == 2)`, `$[*]."name" ? (@ != "x\"y")`, "%abc%", []string{"red"}, `{"name":"abc"}`})
+
+	for _, path := range []string{"unknown", "nested.unknown", "name.x", "nested_list"} {
+		if _, _, err := NewFilter().Path(m, path, FilterEq, 1).toQuery(1, "AND"); err == nil {
+			t.Errorf("Path(%s) should fail", path)
+		}
+	}
+	if _, _, err := NewFilter().Path(m, "nested_list.num", FilterContains, 1).toQuery(1, "AND"); err == nil {
+		t.Error("Path() should fail on contains with integer")
+	}
+
+	ts := time.Date(2021, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	stmt, args, err = NewFilter().Path(&testEventModel{}, "event.at", FilterGte, timestamppb.New(ts)).toQuery(1, "AND")
+	if err != nil {
+		t.Fatalf("toQuery() failed: %s", err)
+	}
+	expectEq(t, stmt, `(to_timestamp(COALESCE((event->'at'->>'seconds')::bigint, 0)) + `+
+		`COALESCE((event->'at'->>'nanos')::bigint, 0) / 1000 * interval '1 microsecond') >= $1`)
+	expectEq(t, args, []interface{}{ts})
+}
+
+type testEventNested struct {
+	At *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=at,proto3" json:"at,omitempty"`
+}
+
+type testEventModel struct {
+	Event *testEventNested `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+}
+
+func (*testEventModel) Reset()        {}
+func (*testEventModel) ProtoMessage() {}
+
+func TestFilterValueTypes(t *testing.T) {
+	ts := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	i64 := int64(7)
+	f := NewFilter().
+		Eq("u32", uint32(3)).
+		Eq("f32", float32(0.5)).
+		Eq("opt", &i64).
+		Eq("status", TestModelStatus(2)).
+		In("u64", []uint64{1, 2}).
+		In("f", []float64{1.5}).
+		ArrContain("flags", []bool{true}).
+		ArrOverlap("ids", pq.Int64Array{1, 2}).
+		ArrContain("times", []*timestamppb.Timestamp{timestamppb.New(ts), nil}).
+		ArrContain("names", []*sval{WrapString("a"), nil}).
+		ArrOverlap("statuses", []TestModelStatus{1, 2}).
+		In("num", uint64(5)).
+		In("empty", []uint32{}).
+		Eq("big", uint64(math.MaxUint64))
+
+	_, _, err := f.toQuery(1, "AND")
+	if err == nil {
+		t.Fatal("toQuery() should fail on uint64 out of bigint range")
+	}
+
+	f.exprList = f.exprList[:len(f.exprList)-1]
+	stmt, args, err := f.toQuery(1, "AND")
+	if err != nil {
+		t.Fatalf("toQuery() failed: %s", err)
+	}
+	expectEq(t, stmt, "u32 = $1 AND f32 = $2 AND opt = $3 AND status = $4 AND u64 = ANY($5) AND f = ANY($6) AND "+
+		"flags::boolean[] @> $7::boolean[] AND ids::bigint[] && $8::bigint[] AND "+
+		"times::timestamptz[] @> $9::timestamptz[] AND names::text[] @> $10::text[] AND "+
+		"statuses::integer[] && $11::integer[] AND num = ANY($12)")
+	expectEq(t, args, []interface{}{
+		int64(3), float64(0.5), int64(7), int32(2), []int64{1, 2}, []float64{1.5}, []bool{true}, []int64{1, 2},
+		[]time.Time{ts}, []string{"a"}, []int32{1, 2}, []int64{5},
+	})
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %s", err)
+	}
+	decoded := NewFilter()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %s", err)
+	}
+	if !decoded.Equal(f) {
+		t.Errorf("decoded filter %s != %s", decoded, f)
+	}
+
+	sargs := filterArgs(&sqlBackend{}, args)
+	expectEq(t, sargs[4], pq.Array([]int64{1, 2}))
+	expectEq(t, sargs[0], int64(3))
+}
+
+func TestStrictFilter(t *testing.T) {
+	var name *string
+	f := NewFilter().Eq("id", 1).Or(NewFilter().Eq("name", name).Eq("descr", "x"))
+
+	stmt, _, err := f.toQuery(1, "AND")
+	if err != nil {
+		t.Fatalf("toQuery() failed: %s", err)
+	}
+	expectEq(t, stmt, "id = $1 AND (descr = $2)")
+
+	_, _, err = f.Strict().toQuery(1, "AND")
+	if !errors.Is(err, ErrIgnoredFilter) {
+		t.Fatalf("toQuery() in strict mode: %v", err)
+	}
+	expectEq(t, err.Error(), "filter expression is ignored: name eq")
+
+	_, _, err = NewFilter().Strict().Or(NewFilter()).toQuery(1, "AND")
+	expectEq(t, err.Error(), "filter expression is ignored: empty or group")
+
+	for _, f := range []*Filter{NewFilter().Or(nil), NewFilter().And(nil), NewFilter().Not(nil)} {
+		stmt, _, err := f.toQuery(1, "AND")
+		if err != nil {
+			t.Fatalf("toQuery() failed: %s", err)
+		}
+		expectEq(t, stmt, "")
+
+		_, _, err = f.Strict().toQuery(1, "AND")
+		if !errors.Is(err, ErrIgnoredFilter) {
+			t.Errorf("toQuery() of nil group in strict mode: %v", err)
+		}
+	}
+}
+
+func TestSubQueryFilter(t *testing.T) {
+	owners := NewRepo(nil, "owners", &TestModel{}, dummyLogger{})
+
+	sub := owners.SelectFields(context.Background(), "id").Where(NewFilter().Eq("name", "bob").RawArgs("count > $1", 5))
+	f := NewFilter().
+		Eq("status", 1).
+		RawArgs("(website = $2 OR descr = $1)", "x", "y").
+		InQuery("id", sub).
+		NotExists(owners.Select(context.Background()).As("o").Where(NewFilter().Raw("o.id = xxx_table.id").Eq("o.status", 3))).
+		Exists(nil)
+
+	stmt, args, err := f.toQuery(1, "AND")
+	if err != nil {
+		t.Fatalf("toQuery() failed: %s", err)
+	}
+	expectEq(t, stmt, "status = $1 AND (website = $3 OR descr = $2) AND "+
+		"id IN (SELECT owners.id FROM owners  WHERE name = $4 AND count > $5 ) AND "+
+		"NOT EXISTS ("+owners.selectQuery("o", nil)+" WHERE o.id = xxx_table.id AND o.status = $6 )")
+	expectEq(t, args, []interface{}{1, "x", "y", "bob", 5, 3})
+
+	_, _, err = NewFilter().RawArgs("a = $2", 1).toQuery(1, "AND")
+	if err == nil {
+		t.Error("toQuery() should fail on placeholder without argument")
+	}
+
+	data, err := json.Marshal(NewFilter().RawArgs("a = $1 OR b = $2", "x", nil))
+	if err != nil {
+		t.Fatalf("Marshal() failed: %s", err)
+	}
+	var decoded Filter
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() failed: %s", err)
+	}
+	expectEq(t, decoded.exprList[0].rval, []interface{}{"x", nil})
+
+	if _, err := json.Marshal(NewFilter().InQuery("id", sub)); err == nil {
+		t.Error("Marshal() should fail on subquery")
+	}
+
+	// subqueries are not built on rendering (pg_trgm check would query nil db)
+	trgmSub := owners.SelectFields(context.Background(), "id").Where(NewFilter().Similar("name", "bob", 0))
+	f = NewFilter().Eq("status", 1).InQuery("id", trgmSub).Or(NewFilter().NotExists(trgmSub).Exists(nil))
+	expectEq(t, f.String(), "status = 1 AND id IN (...) AND (NOT EXISTS (...))")
+	if _, err := json.Marshal(NewFilter().Strict().Exists(trgmSub)); err == nil {
+		t.Error("Marshal() should fail on subquery")
+	}
+}
@@ -0,0 +1,217 @@
+package protosql
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// full text search (to_tsvector / websearch_to_tsquery)
+
+// text search configuration name (e.g. english, simple, public.my_config)
+var searchConfigRe = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)?$`)
+
+// WithSearchConfig sets text search configuration used by Match, RankBy and Headline
+// if configuration is not passed explicitly. By default server's default_text_search_config is used.
+// Note: expression index on to_tsvector is used only if configuration is the same as in index.
+// Invalid configuration name fails on query building.
+func WithSearchConfig(config string) RepoOption {
+	return func(o *repoOptions) {
+		o.searchConfig = config
+	}
+}
+
+type matchVal struct {
+	query  interface{}
+	config string
+	vector bool // lval is tsvector expression
+}
+
+// Match adds full text search condition: to_tsvector(config, lval) @@ websearch_to_tsquery(config, query).
+// Empty config means Repo search config. Empty query is ignored.
+func (f *Filter) Match(lval string, query interface{}, config string) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: matchOp, rval: matchVal{query: query, config: config}})
+	return f
+}
+
+// MatchVector is the same as Match, but lval is tsvector column or expression used as is
+func (f *Filter) MatchVector(lval string, query interface{}, config string) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: matchOp, rval: matchVal{query: query, config: config, vector: true}})
+	return f
+}
+
+// queryArg returns query argument, ignoreFilterErr means no search
+func (m matchVal) queryArg() (interface{}, error) {
+	arg, err := filterExpr{}.arg(m.query)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := arg.(string); !ok || s == "" {
+		if !ok {
+			return nil, fmt.Errorf("text search query must be a string, got %T", arg)
+		}
+		return nil, ignoreFilterErr
+	}
+
+	return arg, nil
+}
+
+func (m matchVal) configArg() (string, error) {
+	if m.config == "" {
+		return "", nil
+	}
+	if !searchConfigRe.MatchString(m.config) {
+		return "", fmt.Errorf("invalid text search config %q", m.config)
+	}
+
+	// config is not bound as parameter, otherwise expression indexes could not be used
+	return fmt.Sprintf("'%s', ", m.config), nil
+}
+
+func (m matchVal) format(lval string, idx int) (string, string, error) {
+	cfg, err := m.configArg()
+	if err != nil {
+		return "", "", err
+	}
+
+	vector := lval
+	if !m.vector {
+		vector = fmt.Sprintf("to_tsvector(%s%s)", cfg, lval)
+	}
+
+	return vector, fmt.Sprintf("websearch_to_tsquery(%s$%d)", cfg, idx), nil
+}
+
+func (f filterExpr) formatMatch(gidx int) (string, []interface{}, error) {
+	m := f.rval.(matchVal)
+
+	arg, err := m.queryArg()
+	if err != nil {
+		return "", nil, err
+	}
+
+	vector, query, err := m.format(f.lval, gidx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("%s @@ %s", vector, query), []interface{}{arg}, nil
+}
+
+// prepare returns filter copy with applied Repo options:
+// Match expressions without config use Repo search config,
+// Similar expressions are replaced by Contain if pg_trgm is not installed
+func (f *Filter) prepare(o queryOpts) *Filter {
+	if f == nil || (o.searchConfig == "" && o.trgm) {
+		return f
+	}
+
+	ret := &Filter{exprList: make([]filterExpr, len(f.exprList)), strict: f.strict, allowAll: f.allowAll}
+	for i, e := range f.exprList {
+		switch {
+		case e.op.isGroup():
+			e.rval = e.rval.(*Filter).prepare(o)
+		case e.op == matchOp:
+			m := e.rval.(matchVal)
+			if m.config == "" {
+				m.config = o.searchConfig
+			}
+			e.rval = m
+		case e.op == similarOp && !o.trgm:
+			e.op, e.rval = containOp, e.rval.(similarVal).term
+		}
+		ret.exprList[i] = e
+	}
+
+	return ret
+}
+
+// RankBy returns sorting by text search relevance (ts_rank) in descending order.
+// lval, query and config have the same meaning as in Filter.Match.
+func RankBy(lval string, query interface{}, config string) *Sorting {
+	return &Sorting{FieldName: lval, Order: "DESC", rank: &matchVal{query: query, config: config}}
+}
+
+// RankByVector is the same as RankBy for tsvector expression
+func RankByVector(lval string, query interface{}, config string) *Sorting {
+	return &Sorting{FieldName: lval, Order: "DESC", rank: &matchVal{query: query, config: config, vector: true}}
+}
+
+func rankQuery(s *Sorting, idx int, config string) (string, []interface{}, error) {
+	m := *s.rank
+	if m.config == "" {
+		m.config = config
+	}
+
+	arg, err := m.queryArg()
+	if err == ignoreFilterErr {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	vector, query, err := m.format(s.FieldName, idx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf(" ORDER BY ts_rank(%s, %s) %s", vector, query, s.Order), []interface{}{arg}, nil
+}
+
+type headline struct {
+	source string
+	m      matchVal
+}
+
+// Headline selects ts_headline snippet of source column (with query matches highlighted)
+// into model field instead of its column value. Empty query is ignored.
+func (q *repoQ) Headline(field, source string, query interface{}, config string) *repoQ {
+	if q.headlines == nil {
+		q.headlines = map[string]headline{}
+	}
+	q.headlines[field] = headline{source: source, m: matchVal{query: query, config: config}}
+	return q
+}
+
+// headlineExprs returns select expressions for headline fields and their args
+func (q *repoQ) headlineExprs(idx int) (map[string]string, []interface{}, error) {
+	var (
+		exprs map[string]string
+		args  []interface{}
+	)
+
+	al := q.alias
+	if al == "" {
+		al = q.r.table
+	}
+
+	for _, field := range q.r.fields {
+		h, ok := q.headlines[field]
+		if !ok {
+			continue
+		}
+		if h.m.config == "" {
+			h.m.config = q.r.searchConfig
+		}
+
+		arg, err := h.m.queryArg()
+		if err == ignoreFilterErr {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("headline %s: %w", field, err)
+		}
+		cfg, err := h.m.configArg()
+		if err != nil {
+			return nil, nil, fmt.Errorf("headline %s: %w", field, err)
+		}
+
+		if exprs == nil {
+			exprs = map[string]string{}
+		}
+		exprs[field] = fmt.Sprintf("ts_headline(%s%s.%s, websearch_to_tsquery(%s$%d)) AS %s", cfg, al, h.source, cfg, idx+len(args), field)
+		args = append(args, arg)
+	}
+
+	return exprs, args, nil
+}
@@ -0,0 +1,43 @@
+package protosql
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GeneratedModel is implemented by messages generated with protoc-gen-protosql.
+// Repo uses these methods instead of reflection for building params and scanning rows.
+type GeneratedModel interface {
+	Model
+
+	SQLColumns() []string
+	SQLParams(c Codec) []interface{}
+	SQLScanDest(c Codec) []interface{}
+}
+
+// TimeArg converts timestamp field into query argument
+func TimeArg(ts *timestamppb.Timestamp) interface{} {
+	return ts.AsTime()
+}
+
+// TimeDest returns scan destination for timestamp field
+func TimeDest(ts **timestamppb.Timestamp) interface{} {
+	return &timeScanner{ts}
+}
+
+// JSONArg converts nested message, map or repeated messages field into query argument
+func JSONArg(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Errorf("cant marshal json '%s': %s", v, err))
+	}
+
+	return b
+}
+
+// JSONDest returns scan destination for json field
+func JSONDest(dest interface{}) interface{} {
+	return &jsonScanner{dest}
+}
@@ -0,0 +1,256 @@
+package protosql
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+func (q *repoQ) InnerJoin(table, bindQ string) *repoQ {
+	q.joins = append(q.joins, join{jtype: "INNER", table: table, bindQ: bindQ})
+	return q
+}
+
+func (q *repoQ) RightJoin(table, bindQ string) *repoQ {
+	q.joins = append(q.joins, join{jtype: "RIGHT", table: table, bindQ: bindQ})
+	return q
+}
+
+func (q *repoQ) CrossJoin(table string) *repoQ {
+	q.joins = append(q.joins, join{jtype: "CROSS", table: table})
+	return q
+}
+
+// LateralJoin left joins subquery as alias (LEFT JOIN LATERAL (...) AS alias ON true).
+// Subquery can refer columns of preceding tables, its arguments are numbered after query arguments.
+func (q *repoQ) LateralJoin(sub *repoQ, alias string) *repoQ {
+	q.joins = append(q.joins, join{jtype: "LEFT", table: alias, bindQ: "true", lateral: sub})
+	return q
+}
+
+// LeftJoinInto left joins table of Repo jr as alias (field name if empty) and scans its columns
+// into nested message field of result. Field is nil if there is no joined row.
+// Field should not be a column of query table, field of other type makes query fail.
+// Joined columns can be used in filter and sorting as alias.column.
+func (q *repoQ) LeftJoinInto(field string, jr *Repo, alias, bindQ string) *repoQ {
+	return q.joinInto("LEFT", field, jr, alias, bindQ)
+}
+
+// InnerJoinInto is the same as LeftJoinInto, but rows without joined row are skipped
+func (q *repoQ) InnerJoinInto(field string, jr *Repo, alias, bindQ string) *repoQ {
+	return q.joinInto("INNER", field, jr, alias, bindQ)
+}
+
+func (q *repoQ) joinInto(jtype, field string, jr *Repo, alias, bindQ string) *repoQ {
+	var ft reflect.Type
+	for _, f := range parseProtoMsg(q.r.model) {
+		if f.name == field {
+			ft = f.val.Type()
+			break
+		}
+	}
+
+	mt := reflect.TypeOf(jr.model)
+	if mt.Kind() != reflect.Ptr {
+		mt = reflect.PtrTo(mt)
+	}
+	if ft != mt {
+		q.err = fmt.Errorf("field '%s' of %T is not %s", field, q.r.model, mt)
+		return q
+	}
+
+	if alias == "" {
+		alias = field
+	}
+
+	n := &nestedJoin{field: field, r: jr, alias: alias}
+	q.joins = append(q.joins, join{jtype: jtype, table: jr.table + " AS " + alias, bindQ: bindQ, nested: n})
+	return q
+}
+
+func (j join) query(idx int) (string, []interface{}, error) {
+	if j.lateral == nil {
+		return j.String(), nil, nil
+	}
+
+	sq, args, err := j.lateral.buildQ(idx, "", j.lateral.pager)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("%s JOIN LATERAL (%s) AS %s ON %s ", j.jtype, sq, j.table, j.bindQ), args, nil
+}
+
+type nestedJoin struct {
+	field string
+	r     *Repo
+	alias string
+}
+
+// columns are aliased as alias__column, first column shows if row is joined
+func (n nestedJoin) selectExprs() []string {
+	exprs := []string{fmt.Sprintf("NOT (ROW(%[1]s.*) IS NULL) AS %[1]s__found", n.alias)}
+	for _, f := range n.r.fields {
+		exprs = append(exprs, fmt.Sprintf("%[1]s.%[2]s AS %[1]s__%[2]s", n.alias, f))
+	}
+	return exprs
+}
+
+type nestedDest struct {
+	found bool
+	names []string
+	dest  []interface{}
+	set   func() // sets nested field after successful scan
+}
+
+// scanDest returns scan destinations of joined model fields
+func (n nestedJoin) scanDest(c Codec, obj Model) (*nestedDest, error) {
+	var fv reflect.Value
+	for _, f := range parseProtoMsg(obj) {
+		if f.name == n.field {
+			fv = f.val
+			break
+		}
+	}
+	if !fv.IsValid() {
+		return nil, fmt.Errorf("unknown field '%s' of %T", n.field, obj)
+	}
+
+	nv := reflect.New(fv.Type().Elem())
+	names, dest, err := fieldsDest(c, nv.Interface().(Model), true)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &nestedDest{names: names, dest: dest}
+	d.set = func() {
+		if d.found {
+			fv.Set(nv)
+		} else {
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+	}
+
+	return d, nil
+}
+
+func (q *repoQ) nestedJoins() []nestedJoin {
+	var ret []nestedJoin
+	for _, j := range q.joins {
+		if j.nested != nil {
+			ret = append(ret, *j.nested)
+		}
+	}
+	return ret
+}
+
+// nestedSelect returns query table fields (nil means all) and select expressions of joined models
+func (q *repoQ) nestedSelect() ([]string, []string) {
+	fields := q.selectFields()
+	nested := q.nestedJoins()
+	if len(nested) == 0 {
+		return fields, nil
+	}
+
+	if fields == nil {
+		skip := q.skipFields()
+		fields = []string{}
+		for _, f := range q.r.fields {
+			if !hasString(skip, f) {
+				fields = append(fields, f)
+			}
+		}
+	}
+
+	var exprs []string
+	for _, n := range nested {
+		exprs = append(exprs, n.selectExprs()...)
+	}
+
+	return fields, exprs
+}
+
+// skipFields returns fields of query model which are not columns of query table
+func (q *repoQ) skipFields() []string {
+	skip := q.r.relationFields()
+	for _, n := range q.nestedJoins() {
+		skip = append(skip, n.field)
+	}
+	return skip
+}
+
+// nullScanner scans scalar column of left joined model, NULL keeps zero value
+type nullScanner struct {
+	dest interface{}
+}
+
+func (s *nullScanner) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+
+	dv := reflect.ValueOf(s.dest).Elem()
+	if b, ok := src.([]byte); ok {
+		src = string(b)
+	}
+
+	sv := reflect.ValueOf(src)
+	if str, ok := src.(string); ok && dv.Kind() != reflect.String {
+		// numeric and boolean values in text format
+		switch dv.Kind() {
+		case reflect.Bool:
+			v, err := strconv.ParseBool(str)
+			if err != nil {
+				return err
+			}
+			sv = reflect.ValueOf(v)
+		case reflect.Float32, reflect.Float64:
+			v, err := strconv.ParseFloat(str, 64)
+			if err != nil {
+				return err
+			}
+			sv = reflect.ValueOf(v)
+		default:
+			v, err := strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				return err
+			}
+			sv = reflect.ValueOf(v)
+		}
+	}
+
+	if !sv.Type().ConvertibleTo(dv.Type()) || (sv.Kind() == reflect.String) != (dv.Kind() == reflect.String) {
+		return fmt.Errorf("can't scan %T into %s", src, dv.Type())
+	}
+	if overflows(sv, dv) {
+		return fmt.Errorf("value %v is out of %s range", sv, dv.Type())
+	}
+	dv.Set(sv.Convert(dv.Type()))
+
+	return nil
+}
+
+// overflows reports whether integer value sv does not fit into dv
+func overflows(sv, dv reflect.Value) bool {
+	switch sv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v := sv.Int()
+		switch dv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return dv.OverflowInt(v)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return v < 0 || dv.OverflowUint(uint64(v))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v := sv.Uint()
+		switch dv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v > math.MaxInt64 || dv.OverflowInt(int64(v))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return dv.OverflowUint(v)
+		}
+	}
+
+	return false
+}
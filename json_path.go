@@ -0,0 +1,292 @@
+package protosql
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// filters on nested messages stored as json (see JSONArg).
+// Nested fields are addressed by proto path, e.g. "nested.name" or "nested_list.num".
+
+// Path adds condition on column or nested message field addressed by proto path of model.
+// op is one of Filter* operations (FilterEq, FilterGt, FilterIn, FilterContains ...).
+// Scalar path is compiled into ->/->> extraction casted by field type, path through
+// repeated fields is compiled into jsonpath existence check (any element matches).
+// Invalid path or operation makes query building fail.
+func (f *Filter) Path(model Model, path, op string, val interface{}) *Filter {
+	jf, err := resolveJsonPath(model, path)
+	if err == nil {
+		// top level repeated scalars are arrays, not json
+		if jf.isList() && (len(jf.segments) > 1 || isMessageType(jf.typ)) {
+			err = f.addJsonPathRule(jf, op, val)
+		} else {
+			var expr string
+			if expr, err = jf.expr(); err == nil {
+				err = f.addRule(FilterRule{Column: expr, Op: op}, preciseTime(val))
+			}
+		}
+	}
+
+	if err != nil {
+		f.addExpr(filterExpr{lval: path, op: invalidOp, rval: fmt.Errorf("filter path %s: %w", path, err)})
+	}
+
+	return f
+}
+
+// preciseTime converts timestamp message to time with nanos
+// (filter argument of TimestampValue has seconds precision)
+func preciseTime(val interface{}) interface{} {
+	ts, ok := val.(interface {
+		TimestampValue
+		GetNanos() int32
+	})
+	if v := reflect.ValueOf(val); !ok || (v.Kind() == reflect.Ptr && v.IsNil()) || ts.GetSeconds() == 0 {
+		return val
+	}
+
+	return time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).UTC()
+}
+
+// JsonPathExists adds jsonpath existence check: lval @? path (e.g. `$.tags[*] ? (@ == "red")`)
+func (f *Filter) JsonPathExists(lval string, path string) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: jsonPathOp, rval: path})
+	return f
+}
+
+// JsonContainsMsg adds jsonb containment check: lval @> partial (only set fields of partial message are compared).
+// For repeated messages column partial should be a slice.
+func (f *Filter) JsonContainsMsg(lval string, partial interface{}) *Filter {
+	var rval interface{}
+	if v := reflect.ValueOf(partial); partial != nil && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		rval = string(JSONArg(partial).([]byte))
+	}
+
+	f.addExpr(filterExpr{lval: lval, op: jsonMsgContainOp, rval: rval})
+	return f
+}
+
+type jsonSegment struct {
+	key  string // json key (column name for first segment)
+	list bool
+}
+
+// jsonField is a field addressed by proto path
+type jsonField struct {
+	segments []jsonSegment
+	typ      reflect.Type // type of leaf (item type for repeated leaf)
+}
+
+func (jf jsonField) isList() bool {
+	for _, s := range jf.segments {
+		if s.list {
+			return true
+		}
+	}
+	return false
+}
+
+func isMessageType(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct &&
+		!t.Implements(timeIfaceType) && !t.Implements(durationIfaceType)
+}
+
+func isListType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8
+}
+
+func resolveJsonPath(model Model, path string) (jsonField, error) {
+	names := strings.Split(path, ".")
+
+	var jf jsonField
+	for _, pf := range parseProtoMsg(model) {
+		if pf.name == names[0] {
+			jf.typ = pf.val.Type()
+			break
+		}
+	}
+	if jf.typ == nil {
+		return jf, fmt.Errorf("unknown field '%s'", names[0])
+	}
+
+	for i, name := range names {
+		if i > 0 {
+			if !isMessageType(jf.typ) {
+				return jf, fmt.Errorf("field '%s' has no nested fields", strings.Join(names[:i], "."))
+			}
+
+			sf, key, ok := nestedField(jf.typ.Elem(), name)
+			if !ok {
+				return jf, fmt.Errorf("unknown field '%s'", strings.Join(names[:i+1], "."))
+			}
+			jf.typ = sf.Type
+			name = key
+		}
+
+		seg := jsonSegment{key: name, list: isListType(jf.typ)}
+		if seg.list {
+			jf.typ = jf.typ.Elem()
+		}
+		jf.segments = append(jf.segments, seg)
+	}
+
+	return jf, nil
+}
+
+// nestedField finds struct field by proto name and returns its json key
+func nestedField(t reflect.Type, name string) (reflect.StructField, string, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if n, ok := getDataFieldName(sf); !ok || n != name {
+			continue
+		}
+
+		key := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if key == "" {
+			key = sf.Name
+		}
+		return sf, key, true
+	}
+
+	return reflect.StructField{}, "", false
+}
+
+func jsonKey(k string) string {
+	return "'" + strings.ReplaceAll(k, "'", "''") + "'"
+}
+
+// expr returns SQL expression of scalar path. Zero values are omitted in json,
+// so missing keys are coalesced to zero value of field type.
+func (jf jsonField) expr() (string, error) {
+	if len(jf.segments) == 1 {
+		return jf.segments[0].key, nil
+	}
+
+	e := jf.segments[0].key
+	last := len(jf.segments) - 1
+	for _, s := range jf.segments[1:last] {
+		e += "->" + jsonKey(s.key)
+	}
+	leaf := jsonKey(jf.segments[last].key)
+
+	t := jf.typ
+	switch {
+	case t.Implements(timeIfaceType):
+		// nanos are truncated to microseconds (precision of timestamp)
+		return fmt.Sprintf("(to_timestamp(COALESCE((%[1]s->%[2]s->>'seconds')::bigint, 0)) + "+
+			"COALESCE((%[1]s->%[2]s->>'nanos')::bigint, 0) / 1000 * interval '1 microsecond')", e, leaf), nil
+	case t.Implements(durationIfaceType):
+		// durations are compared in milliseconds
+		return fmt.Sprintf("(COALESCE((%[1]s->%[2]s->>'seconds')::bigint, 0) * 1000 + COALESCE((%[1]s->%[2]s->>'nanos')::bigint, 0) / 1000000)", e, leaf), nil
+	}
+
+	e += "->>" + leaf
+	switch t.Kind() {
+	case reflect.Bool:
+		return fmt.Sprintf("COALESCE((%s)::boolean, false)", e), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("COALESCE((%s)::bigint, 0)", e), nil
+	case reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("COALESCE((%s)::double precision, 0)", e), nil
+	case reflect.String:
+		return fmt.Sprintf("COALESCE(%s, '')", e), nil
+	}
+
+	return "", fmt.Errorf("field of type %s is not comparable", t)
+}
+
+// jsonPath returns jsonpath of leaf values ($ is column value)
+func (jf jsonField) jsonPath() string {
+	p := "$"
+	for i, s := range jf.segments {
+		if i > 0 {
+			p += "." + jsonLiteral(s.key)
+		}
+		if s.list {
+			p += "[*]"
+		}
+	}
+	return p
+}
+
+func jsonLiteral(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+var jsonPathOps = map[string]string{
+	FilterEq:  "==",
+	FilterNeq: "!=",
+	FilterGt:  ">",
+	FilterGte: ">=",
+	FilterLt:  "<",
+	FilterLte: "<=",
+}
+
+func (f *Filter) addJsonPathRule(jf jsonField, op string, val interface{}) error {
+	if jf.typ.Kind() == reflect.Ptr || jf.typ.Kind() == reflect.Map || jf.typ.Kind() == reflect.Slice {
+		return fmt.Errorf("field of type %s is not comparable", jf.typ)
+	}
+
+	arg, err := filterExpr{}.arg(val)
+	if err == ignoreFilterErr {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var items []interface{}
+	if v := reflect.ValueOf(arg); v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, v.Index(i).Interface())
+		}
+	} else {
+		items = []interface{}{arg}
+	}
+	for _, item := range items {
+		switch item.(type) {
+		case string, bool, int, int32, int64:
+		default:
+			return fmt.Errorf("unsupported value type %T", item)
+		}
+	}
+
+	path := jf.jsonPath()
+	predicates := func(op string) []string {
+		var ret []string
+		for _, item := range items {
+			ret = append(ret, fmt.Sprintf("@ %s %s", op, jsonLiteral(item)))
+		}
+		return ret
+	}
+
+	switch op {
+	case FilterEq, FilterNeq, FilterGt, FilterGte, FilterLt, FilterLte:
+		if len(items) != 1 {
+			return fmt.Errorf("operation %q needs single value", op)
+		}
+		f.JsonPathExists(jf.segments[0].key, fmt.Sprintf("%s ? (%s)", path, predicates(jsonPathOps[op])[0]))
+	case FilterIn, FilterArrOverlap:
+		f.JsonPathExists(jf.segments[0].key, fmt.Sprintf("%s ? (%s)", path, strings.Join(predicates("=="), " || ")))
+	case FilterArrContain:
+		// every value should be found
+		for _, p := range predicates("==") {
+			f.JsonPathExists(jf.segments[0].key, fmt.Sprintf("%s ? (%s)", path, p))
+		}
+	case FilterContains:
+		s, ok := arg.(string)
+		if !ok {
+			return fmt.Errorf("operation %q needs string value", op)
+		}
+		f.JsonPathExists(jf.segments[0].key, fmt.Sprintf(`%s ? (@ like_regex %s flag "iq")`, path, jsonLiteral(s)))
+	default:
+		return fmt.Errorf("unknown filter operation %q", op)
+	}
+
+	return nil
+}
@@ -0,0 +1,313 @@
+package protosql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNoMigrations     = errors.New("no applied migrations")
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+)
+
+const (
+	migrationsTable = "schema_migrations"
+	// pg_advisory_xact_lock key shared by all replicas
+	migrationsLockKey = 7253841092467311
+)
+
+// Migrator applies numbered migrations from fs.FS (e.g. embed.FS).
+// Files must be named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// the same as produced by WriteMigration.
+type Migrator struct {
+	r    *Repo
+	fsys fs.FS
+}
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is true if applied migration differs from file
+	Modified bool
+}
+
+func NewMigrator(db *sql.DB, fsys fs.FS, logger Logger) *Migrator {
+	return &Migrator{r: newRepo(&sqlBackend{db: db}, migrationsTable, DummyModel{}, logger), fsys: fsys}
+}
+
+func NewPgxMigrator(pool *pgxpool.Pool, fsys fs.FS, logger Logger) *Migrator {
+	return &Migrator{r: newRepo(&pgxBackend{pool: pool}, migrationsTable, DummyModel{}, logger), fsys: fsys}
+}
+
+// Migrations returns migrations found in fs ordered by version
+func (m *Migrator) Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		match := migrationFileRe.FindStringSubmatch(e.Name())
+		if match == nil || e.IsDir() {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		name := match[2]
+
+		raw, err := fs.ReadFile(m.fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: name}
+			byVersion[version] = mg
+		} else if mg.Name != name {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, mg.Name, name)
+		}
+
+		if match[3] == "up" {
+			mg.Up = string(raw)
+			sum := sha256.Sum256(raw)
+			mg.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mg.Down = string(raw)
+		}
+	}
+
+	var ret []Migration
+	for _, mg := range byVersion {
+		if mg.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mg.Version, mg.Name)
+		}
+		ret = append(ret, *mg)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Version < ret[j].Version })
+
+	return ret, nil
+}
+
+type appliedMigration struct {
+	version   int64
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	return m.r.Transaction(ctx, func(ctx context.Context) error {
+		if err := m.lock(ctx); err != nil {
+			return err
+		}
+
+		return m.r.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version bigint NOT NULL PRIMARY KEY,
+	name text NOT NULL,
+	checksum text NOT NULL,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)`, migrationsTable))
+	})
+}
+
+// lock must be called inside transaction, it is released on commit/rollback
+func (m *Migrator) lock(ctx context.Context) error {
+	return m.r.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", int64(migrationsLockKey))
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]appliedMigration, error) {
+	q := fmt.Sprintf("SELECT version, checksum, applied_at FROM %s", migrationsTable)
+	m.r.logger.Debugf("QUERY: %s", q)
+
+	rows, err := m.r.b.query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := map[int64]appliedMigration{}
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		ret[a.version] = a
+	}
+
+	return ret, rows.Err()
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	// status does not create migrations table, all migrations are pending without it
+	var exists bool
+	if err := m.r.queryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", []interface{}{migrationsTable}, &exists); err != nil {
+		return nil, err
+	}
+
+	applied := map[int64]appliedMigration{}
+	if exists {
+		if applied, err = m.applied(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var ret []MigrationStatus
+	for _, mg := range migrations {
+		st := MigrationStatus{Version: mg.Version, Name: mg.Name}
+		if a, ok := applied[mg.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = a.appliedAt
+			st.Modified = a.checksum != mg.Checksum
+		}
+		ret = append(ret, st)
+	}
+
+	return ret, nil
+}
+
+// Up applies all pending migrations, each one in separate transaction.
+// Returns number of applied migrations.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.up(ctx, 0)
+}
+
+// up applies at most limit pending migrations (0 means all)
+func (m *Migrator) up(ctx context.Context, limit int) (int, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, mg := range migrations {
+		var done bool
+		err := m.r.Transaction(ctx, func(ctx context.Context) error {
+			if err := m.lock(ctx); err != nil {
+				return err
+			}
+
+			// other replica could apply migrations while we were waiting for lock
+			applied, err := m.applied(ctx)
+			if err != nil {
+				return err
+			}
+			if a, ok := applied[mg.Version]; ok {
+				if a.checksum != mg.Checksum {
+					return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, mg.Version, mg.Name)
+				}
+				return nil
+			}
+
+			m.r.logger.Infof("applying migration %d_%s", mg.Version, mg.Name)
+
+			if err := m.r.Exec(ctx, mg.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, err)
+			}
+
+			done = true
+			return m.r.Exec(
+				ctx,
+				fmt.Sprintf("INSERT INTO %s (version, name, checksum) VALUES ($1, $2, $3)", migrationsTable),
+				mg.Version, mg.Name, mg.Checksum,
+			)
+		})
+		if err != nil {
+			return n, err
+		}
+		if done {
+			n++
+		}
+		if limit > 0 && n == limit {
+			break
+		}
+	}
+
+	return n, nil
+}
+
+// Down reverts the last applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return err
+	}
+
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+
+	return m.r.Transaction(ctx, func(ctx context.Context) error {
+		if err := m.lock(ctx); err != nil {
+			return err
+		}
+
+		var version int64
+		err := m.r.queryRow(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", migrationsTable), nil, &version)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			return ErrNoMigrations
+		}
+
+		for _, mg := range migrations {
+			if mg.Version != version {
+				continue
+			}
+			if mg.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", mg.Version, mg.Name)
+			}
+
+			m.r.logger.Infof("reverting migration %d_%s", mg.Version, mg.Name)
+
+			if err := m.r.Exec(ctx, mg.Down); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, err)
+			}
+
+			return m.r.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE version = $1", migrationsTable), version)
+		}
+
+		return fmt.Errorf("applied migration %d not found", version)
+	})
+}
+
+// Redo reverts the last applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) error {
+	if err := m.Down(ctx); err != nil {
+		return err
+	}
+
+	_, err := m.up(ctx, 1)
+	return err
+}
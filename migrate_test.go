@@ -0,0 +1,106 @@
+package protosql
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMigratorUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"0001_init.up.sql":       {Data: []byte("CREATE TABLE xxx_table (id integer)")},
+		"0001_init.down.sql":     {Data: []byte("DROP TABLE xxx_table")},
+		"0002_add_name.up.sql":   {Data: []byte("ALTER TABLE xxx_table ADD COLUMN name text")},
+		"0002_add_name.down.sql": {Data: []byte("ALTER TABLE xxx_table DROP COLUMN name")},
+		"README.md":              {Data: []byte("not a migration")},
+	}
+
+	m := NewMigrator(db, fsys, dummyLogger{})
+	migrations, err := m.Migrations()
+	if err != nil {
+		t.Fatalf("Migrations() failed: %s", err)
+	}
+	expectEq(t, len(migrations), 2)
+	expectEq(t, migrations[1].Name, "add_name")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// first migration is already applied by other replica
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, checksum, applied_at FROM schema_migrations").WillReturnRows(
+		sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).AddRow(1, migrations[0].Checksum, testModel.CreateTime.AsTime()),
+	)
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, checksum, applied_at FROM schema_migrations").WillReturnRows(
+		sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).AddRow(1, migrations[0].Checksum, testModel.CreateTime.AsTime()),
+	)
+	mock.ExpectExec("ALTER TABLE xxx_table ADD COLUMN name text").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(int64(2), "add_name", migrations[1].Checksum).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := m.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Up() failed: %s", err)
+	}
+	expectEq(t, n, 1)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigratorStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"0001_init.up.sql":     {Data: []byte("CREATE TABLE xxx_table (id integer)")},
+		"0002_add_name.up.sql": {Data: []byte("ALTER TABLE xxx_table ADD COLUMN name text")},
+	}
+	m := NewMigrator(db, fsys, dummyLogger{})
+
+	// migrations table is not created by status check
+	mock.ExpectQuery("SELECT to_regclass").WithArgs("schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	st, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status() failed: %s", err)
+	}
+	expectEq(t, len(st), 2)
+	expectEq(t, st[0].Applied || st[1].Applied, false)
+
+	mock.ExpectQuery("SELECT to_regclass").WithArgs("schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT version, checksum, applied_at FROM schema_migrations").WillReturnRows(
+		sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).AddRow(1, "changed", testModel.CreateTime.AsTime()),
+	)
+
+	st, err = m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status() failed: %s", err)
+	}
+	expectEq(t, st[0].Applied, true)
+	expectEq(t, st[0].Modified, true)
+	expectEq(t, st[1].Applied, false)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
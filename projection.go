@@ -0,0 +1,68 @@
+package protosql
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Project selects only listed model fields, other fields of fetched models are left unset.
+// Fields are selected in model order. Unknown field makes query fail.
+func (q *repoQ) Project(fields ...string) *repoQ {
+	var names []string
+	for _, f := range parseProtoMsg(q.r.model) {
+		names = append(names, f.name)
+	}
+
+	for _, f := range fields {
+		if !hasString(names, f) {
+			q.err = fmt.Errorf("unknown field '%s' of %T", f, q.r.model)
+			return q
+		}
+	}
+
+	q.fields = []string{}
+	for _, name := range names {
+		if hasString(fields, name) {
+			q.fields = append(q.fields, name)
+		}
+	}
+
+	return q
+}
+
+// ReadMask projects fields by AIP-157 read mask: empty mask or "*" means all fields,
+// nested paths (e.g. "nested.name") select the whole top level field.
+func (q *repoQ) ReadMask(mask *fieldmaskpb.FieldMask) *repoQ {
+	var fields []string
+	for _, p := range mask.GetPaths() {
+		if p == "*" {
+			return q
+		}
+		fields = append(fields, strings.Split(p, ".")[0])
+	}
+
+	if len(fields) == 0 {
+		return q
+	}
+
+	return q.Project(fields...)
+}
+
+// selectFields returns projected columns of query table, nil means all columns
+func (q *repoQ) selectFields() []string {
+	if q.fields == nil {
+		return nil
+	}
+
+	skip := q.skipFields()
+	ret := []string{}
+	for _, f := range q.fields {
+		if !hasString(skip, f) {
+			ret = append(ret, f)
+		}
+	}
+
+	return ret
+}
@@ -1,13 +1,10 @@
 package protosql
 
 import (
-	"encoding/json"
-	"fmt"
 	"reflect"
 	"strings"
 	"time"
 
-	"github.com/lib/pq"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -19,6 +16,7 @@ type Model interface {
 type parsedField struct {
 	name string
 	val  reflect.Value
+	tag  reflect.StructTag
 }
 
 func parseProtoMsg(m Model) []parsedField {
@@ -40,13 +38,56 @@ func parseProtoMsg(m Model) []parsedField {
 		if !ok {
 			continue
 		}
-		r = append(r, parsedField{name: val, val: v.Field(i)})
+		r = append(r, parsedField{name: val, val: v.Field(i), tag: t.Field(i).Tag})
 	}
 
 	return r
 }
 
-func toSqlParams(params []parsedField) ([]string, []interface{}) {
+// fields from skip list are not columns of table (e.g. relations)
+func modelParams(c Codec, obj Model, skip ...string) ([]string, []interface{}) {
+	if g, ok := obj.(GeneratedModel); ok {
+		return skipColumns(g.SQLColumns(), g.SQLParams(c), skip)
+	}
+
+	names, values := toSqlParams(c, parseProtoMsg(obj))
+	return skipColumns(names, values, skip)
+}
+
+// skipColumns removes skipped columns and corresponding values
+func skipColumns(names []string, values []interface{}, skip []string) ([]string, []interface{}) {
+	if len(skip) == 0 {
+		return names, values
+	}
+
+	var (
+		retNames  []string
+		retValues []interface{}
+	)
+	for i, name := range names {
+		if !hasString(skip, name) {
+			retNames = append(retNames, name)
+			retValues = append(retValues, values[i])
+		}
+	}
+
+	return retNames, retValues
+}
+
+func hasString(list []string, s string) bool {
+	return indexOf(list, s) >= 0
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func toSqlParams(c Codec, params []parsedField) ([]string, []interface{}) {
 	var (
 		names  []string
 		values []interface{}
@@ -54,7 +95,7 @@ func toSqlParams(params []parsedField) ([]string, []interface{}) {
 
 	for _, p := range params {
 		names = append(names, p.name)
-		values = append(values, toSqlParam(p.val))
+		values = append(values, toSqlParam(c, p.val))
 	}
 
 	return names, values
@@ -68,12 +109,12 @@ type durationIface interface {
 	AsDuration() time.Duration
 }
 
-func toSqlParam(v reflect.Value) interface{} {
+func toSqlParam(c Codec, v reflect.Value) interface{} {
 	switch e := v.Interface().(type) {
 	case timeIface:
 		return e.AsTime()
 	case durationIface:
-		return e.AsDuration().Milliseconds()
+		return c.DurationArg(e.AsDuration())
 	}
 
 	switch v.Type().Kind() {
@@ -93,7 +134,7 @@ func toSqlParam(v reflect.Value) interface{} {
 		case []byte:
 			return v.Interface()
 		default:
-			return pq.Array(v.Interface())
+			return c.ArrayArg(v.Interface())
 		}
 	case reflect.Map:
 		return toJson(v)
@@ -109,12 +150,7 @@ func toSqlParam(v reflect.Value) interface{} {
 }
 
 func toJson(v reflect.Value) interface{} {
-	b, err := json.Marshal(v.Interface())
-	if err != nil {
-		panic(fmt.Errorf("cant marshal json '%s': %s", v.Interface(), err))
-	}
-
-	return b
+	return JSONArg(v.Interface())
 }
 
 func getDataFieldName(f reflect.StructField) (string, bool) {
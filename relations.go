@@ -0,0 +1,311 @@
+package protosql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// relations between Repos and preloading of related rows (see repoQ.Preload).
+// Relation field is a message field of model which is not stored in table,
+// it is filled only by Preload. Relations should be declared before Repo usage.
+// Invalid declaration is reported by Repo.Validate and Preload of the field.
+
+type relationKind int
+
+const (
+	hasMany relationKind = iota
+	belongsTo
+	manyToMany
+)
+
+type relation struct {
+	kind    relationKind
+	related *Repo
+
+	// column of related rows matched with key of model:
+	// foreign key (has many), id (belongs to) or join table column (many to many)
+	relatedKey string
+	// field of model with key value: id or foreign key (belongs to)
+	localKey string
+
+	joinTable  string
+	joinRelKey string // join table column referencing related rows
+}
+
+// HasMany declares that rows of related Repo reference rows of r by foreignKey column
+// (related.foreignKey = r.id), field is a repeated message field of r model
+func (r *Repo) HasMany(field string, related *Repo, foreignKey string) *Repo {
+	return r.addRelation(field, &relation{kind: hasMany, related: related, relatedKey: related.table + "." + foreignKey, localKey: "id"})
+}
+
+// BelongsTo declares that rows of r reference rows of related Repo by localKey column
+// (r.localKey = related.id), field is a message field of r model
+func (r *Repo) BelongsTo(field string, related *Repo, localKey string) *Repo {
+	return r.addRelation(field, &relation{kind: belongsTo, related: related, relatedKey: related.table + ".id", localKey: localKey})
+}
+
+// ManyToMany declares relation via joinTable, which references rows of r by localKey column
+// and rows of related Repo by foreignKey column, field is a repeated message field of r model
+func (r *Repo) ManyToMany(field string, related *Repo, joinTable, localKey, foreignKey string) *Repo {
+	return r.addRelation(field, &relation{
+		kind:       manyToMany,
+		related:    related,
+		relatedKey: joinTable + "." + localKey,
+		localKey:   "id",
+		joinTable:  joinTable,
+		joinRelKey: joinTable + "." + foreignKey,
+	})
+}
+
+// WithHasMany declares relation as Repo.HasMany does before schema validation of NewRepo
+func WithHasMany(field string, related *Repo, foreignKey string) RepoOption {
+	return withRelation(func(r *Repo) { r.HasMany(field, related, foreignKey) })
+}
+
+// WithBelongsTo declares relation as Repo.BelongsTo does before schema validation of NewRepo
+func WithBelongsTo(field string, related *Repo, localKey string) RepoOption {
+	return withRelation(func(r *Repo) { r.BelongsTo(field, related, localKey) })
+}
+
+// WithManyToMany declares relation as Repo.ManyToMany does before schema validation of NewRepo
+func WithManyToMany(field string, related *Repo, joinTable, localKey, foreignKey string) RepoOption {
+	return withRelation(func(r *Repo) { r.ManyToMany(field, related, joinTable, localKey, foreignKey) })
+}
+
+func withRelation(declare func(*Repo)) RepoOption {
+	return func(o *repoOptions) {
+		o.relations = append(o.relations, declare)
+	}
+}
+
+func (r *Repo) addRelation(field string, rel *relation) *Repo {
+	var ft reflect.Type
+	hasKey := false
+	for _, f := range parseProtoMsg(r.model) {
+		switch f.name {
+		case field:
+			ft = f.val.Type()
+		case rel.localKey:
+			hasKey = true
+		}
+	}
+	if !hasKey {
+		return r.invalidRelation(field, fmt.Errorf("%T has no key field '%s'", r.model, rel.localKey))
+	}
+
+	mt := reflect.TypeOf(rel.related.model)
+	if mt.Kind() != reflect.Ptr {
+		mt = reflect.PtrTo(mt)
+	}
+	if rel.kind != belongsTo {
+		mt = reflect.SliceOf(mt)
+	}
+	if ft != mt {
+		return r.invalidRelation(field, fmt.Errorf("field '%s' of %T is not %s", field, r.model, mt))
+	}
+
+	if r.relations == nil {
+		r.relations = map[string]*relation{}
+	}
+	r.relations[field] = rel
+	delete(r.relationErrs, field)
+
+	var fields []string
+	for _, f := range r.fields {
+		if f != field {
+			fields = append(fields, f)
+		}
+	}
+	r.fields = fields
+
+	return r
+}
+
+func (r *Repo) invalidRelation(field string, err error) *Repo {
+	if r.relationErrs == nil {
+		r.relationErrs = map[string]error{}
+	}
+	r.relationErrs[field] = fmt.Errorf("relation %s: %w", field, err)
+	return r
+}
+
+// relationsErr returns error of invalid relation declarations
+func (r *Repo) relationsErr() error {
+	var fields []string
+	for field := range r.relationErrs {
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	sort.Strings(fields)
+
+	return r.relationErrs[fields[0]]
+}
+
+// relationFields returns sorted model fields which are not columns of table
+func (r *Repo) relationFields() []string {
+	var ret []string
+	for field := range r.relations {
+		ret = append(ret, field)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Preload loads related rows of declared relations (see Repo.HasMany, BelongsTo, ManyToMany)
+// into relation fields of fetched models. Each relation is loaded by single query.
+func (q *repoQ) Preload(fields ...string) *repoQ {
+	q.preloads = append(q.preloads, fields...)
+	return q
+}
+
+// preload fills relation fields of objs (pointers to models)
+func (q *repoQ) preload(objs []reflect.Value) error {
+	if len(objs) == 0 {
+		return nil
+	}
+
+	for _, field := range q.preloads {
+		if err, ok := q.r.relationErrs[field]; ok {
+			return err
+		}
+		rel, ok := q.r.relations[field]
+		if !ok {
+			return fmt.Errorf("unknown relation '%s' of %T", field, q.r.model)
+		}
+		if err := rel.load(q.ctx, field, objs); err != nil {
+			return fmt.Errorf("preload %s: %w", field, err)
+		}
+	}
+
+	return nil
+}
+
+func (rel *relation) load(ctx context.Context, field string, objs []reflect.Value) error {
+	keys := relationKeys(objs, rel.localKey)
+	if keys.Len() == 0 {
+		return nil
+	}
+
+	rr := rel.related
+	sq := rr.selectQueryExprs("", nil, nil, fmt.Sprintf("%s::text AS relation_key", rel.relatedKey))
+	q := rr.SelectCustom(ctx, sq).Where(NewFilter().In(rel.relatedKey, keys.Interface()))
+
+	// related rows are ordered by primary key within each key
+	order := rel.relatedKey
+	if id := rr.table + ".id"; id != rel.relatedKey && hasString(rr.fields, "id") {
+		order += ", " + id
+	}
+	q.OrderBy(Asc(order))
+	if rel.kind == manyToMany {
+		q.InnerJoin(rel.joinTable, fmt.Sprintf("%s = %s.id", rel.joinRelKey, rr.table))
+	}
+
+	rows, err := q.exec()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	mt := reflect.TypeOf(rr.model)
+	if mt.Kind() == reflect.Ptr {
+		mt = mt.Elem()
+	}
+
+	related := map[string][]reflect.Value{}
+	for rows.Next() {
+		obj := reflect.New(mt)
+		dest, err := scanDest(rr.b, obj.Interface().(Model), nil, rr.relationFields(), false)
+		if err != nil {
+			return err
+		}
+
+		var key string
+		if err := rows.Scan(append(dest, &key)...); err != nil {
+			return err
+		}
+		related[key] = append(related[key], obj)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, obj := range objs {
+		fv := modelField(obj, field)
+		key, ok := relationKey(modelField(obj, rel.localKey))
+		if !ok {
+			continue
+		}
+
+		items := related[key]
+		if rel.kind == belongsTo {
+			if len(items) > 0 {
+				fv.Set(items[0])
+			}
+			continue
+		}
+
+		lst := reflect.MakeSlice(fv.Type(), 0, len(items))
+		fv.Set(reflect.Append(lst, items...))
+	}
+
+	return nil
+}
+
+func modelField(obj reflect.Value, name string) reflect.Value {
+	for _, f := range parseProtoMsg(obj.Interface().(Model)) {
+		if f.name == name {
+			return f.val
+		}
+	}
+	return reflect.Value{}
+}
+
+// relationKey returns key as text (related keys are selected as text), false for missing key
+func relationKey(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	if v.IsZero() {
+		return "", false
+	}
+
+	return fmt.Sprint(v.Interface()), true
+}
+
+// relationKeys returns unique non empty keys of objs as typed slice (query argument)
+func relationKeys(objs []reflect.Value, name string) reflect.Value {
+	var (
+		ret  reflect.Value
+		seen = map[string]bool{}
+	)
+
+	for _, obj := range objs {
+		v := modelField(obj, name)
+		key, ok := relationKey(v)
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		if !ret.IsValid() {
+			ret = reflect.MakeSlice(reflect.SliceOf(v.Type()), 0, len(objs))
+		}
+		ret = reflect.Append(ret, v)
+	}
+
+	if !ret.IsValid() {
+		return reflect.ValueOf([]string{})
+	}
+
+	return ret
+}
@@ -5,20 +5,89 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Repo struct {
-	db     *sql.DB
+	b      backend
 	table  string
+	model  Model
 	fields []string
 	logger Logger
+
+	searchConfig string
+
+	extMu      sync.Mutex
+	extensions map[string]extension // extensions cache
+
+	relations    map[string]*relation // field -> declared relation
+	relationErrs map[string]error     // field -> invalid relation declaration
+
+	strictScan bool
+	plansMu    sync.Mutex
+	plans      map[string]*scanPlan // scan plans cache (see scanPlan)
 }
 
-func NewRepo(db *sql.DB, tableName string, obj Model, logger Logger) *Repo {
-	return &Repo{table: tableName, db: db, fields: objFields(obj), logger: logger}
+type RepoOption func(*repoOptions)
+
+type repoOptions struct {
+	validate     bool
+	searchConfig string
+	strictScan   bool
+	relations    []func(*Repo) // relations declared by options (see WithHasMany)
+}
+
+// schemaValidationTimeout bounds schema validation of NewRepo (see WithSchemaValidation)
+const schemaValidationTimeout = 30 * time.Second
+
+// WithSchemaValidation makes NewRepo panic if table does not match the model or database
+// is not reachable within schemaValidationTimeout (see Repo.Validate). Call Repo.Validate
+// at startup instead to handle the error. Validation runs before NewRepo returns,
+// so relations of model should be declared by options (WithHasMany, WithBelongsTo,
+// WithManyToMany) instead of Repo methods.
+func WithSchemaValidation() RepoOption {
+	return func(o *repoOptions) {
+		o.validate = true
+	}
+}
+
+func NewRepo(db *sql.DB, tableName string, obj Model, logger Logger, opts ...RepoOption) *Repo {
+	return newRepo(&sqlBackend{db: db}, tableName, obj, logger, opts...)
+}
+
+// NewPgxRepo creates Repo that works over native pgx v5 pool
+func NewPgxRepo(pool *pgxpool.Pool, tableName string, obj Model, logger Logger, opts ...RepoOption) *Repo {
+	return newRepo(&pgxBackend{pool: pool}, tableName, obj, logger, opts...)
+}
+
+func newRepo(b backend, tableName string, obj Model, logger Logger, opts ...RepoOption) *Repo {
+	r := &Repo{table: tableName, b: b, model: obj, fields: objFields(obj), logger: logger}
+
+	var o repoOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	r.searchConfig = o.searchConfig
+	r.strictScan = o.strictScan
+
+	for _, rel := range o.relations {
+		rel(r)
+	}
+
+	if o.validate {
+		ctx, cancel := context.WithTimeout(context.Background(), schemaValidationTimeout)
+		defer cancel()
+
+		if err := r.Validate(ctx); err != nil {
+			panic(err)
+		}
+	}
+
+	return r
 }
 
 func (r *Repo) Insert(ctx context.Context, obj Model) error {
@@ -26,13 +95,13 @@ func (r *Repo) Insert(ctx context.Context, obj Model) error {
 	trySetTime(obj, "CreateTime", ts)
 	trySetTime(obj, "UpdateTime", ts)
 
-	q, params := insertQ(r.table, obj)
+	q, params := insertQ(r.b, r.table, obj, r.relationFields()...)
 
 	defer addMetricSince("insert", q, time.Now())
 
 	r.logger.Debugf("QUERY: %s, ARGS: %+v", q, params)
 
-	_, err := r.getDB(ctx).ExecContext(ctx, q, params...)
+	_, err := r.b.exec(ctx, q, params...)
 
 	return err
 }
@@ -42,7 +111,7 @@ func (r *Repo) InsertDuplicateIgnore(ctx context.Context, obj Model) (bool, erro
 	trySetTime(obj, "CreateTime", ts)
 	trySetTime(obj, "UpdateTime", ts)
 
-	q, params := insertQ(r.table, obj)
+	q, params := insertQ(r.b, r.table, obj, r.relationFields()...)
 
 	q += " ON CONFLICT(id) DO NOTHING"
 
@@ -50,13 +119,11 @@ func (r *Repo) InsertDuplicateIgnore(ctx context.Context, obj Model) (bool, erro
 
 	r.logger.Debugf("QUERY: %s, ARGS: %+v", q, params)
 
-	res, err := r.getDB(ctx).ExecContext(ctx, q, params...)
+	ra, err := r.b.exec(ctx, q, params...)
 	if err != nil {
 		return false, err
 	}
 
-	ra, _ := res.RowsAffected()
-
 	return ra > 0, err
 }
 
@@ -65,7 +132,7 @@ func (r *Repo) Exec(ctx context.Context, q string, params ...interface{}) error
 
 	defer addMetricSince("exec", q, time.Now())
 
-	_, err := r.getDB(ctx).ExecContext(ctx, q, params...)
+	_, err := r.b.exec(ctx, q, params...)
 	return err
 }
 
@@ -74,32 +141,19 @@ func (r *Repo) ExecBatch(ctx context.Context, q string, params [][]interface{})
 
 	defer addMetricSince("exec_batch", q, time.Now())
 
-	stmt, err := r.getDB(ctx).PrepareContext(ctx, q)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	for _, row := range params {
-		_, err = stmt.ExecContext(ctx, row...)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return r.b.execBatch(ctx, q, params)
 }
 
 func (r *Repo) UpdateByID(ctx context.Context, obj Model) error {
 	tryUpdateTime(obj, "UpdateTime", timestamppb.Now())
 
-	q, params := updateQ(r.table, obj, "id")
+	q, params := updateQ(r.b, r.table, obj, "id", r.relationFields()...)
 
 	defer addMetricSince("update", q, time.Now())
 
 	r.logger.Debugf("QUERY: %s, ARGS: %+v", q, params)
 
-	_, err := r.getDB(ctx).ExecContext(ctx, q, params...)
+	_, err := r.b.exec(ctx, q, params...)
 
 	return err
 }
@@ -107,28 +161,47 @@ func (r *Repo) UpdateByID(ctx context.Context, obj Model) error {
 func (r *Repo) Update(ctx context.Context, obj Model, f *Filter) error {
 	tryUpdateTime(obj, "UpdateTime", timestamppb.Now())
 
-	q, params := updateQ(r.table, obj, "")
-	stmt, args, err := f.toQuery(len(params)+1, "AND")
+	q, params := updateQ(r.b, r.table, obj, "", r.relationFields()...)
+	o, err := r.queryOpts(ctx, f.usesTrgm())
 	if err != nil {
 		return err
 	}
+
+	stmt, args, err := f.prepare(o).toQuery(len(params)+1, "AND")
+	if err != nil {
+		return err
+	}
+	if err := f.unfiltered(stmt); err != nil {
+		return err
+	}
+	if stmt == "" {
+		q = strings.TrimSuffix(q, " WHERE ")
+	}
 	q += stmt
-	params = append(params, args...)
+	params = append(params, filterArgs(r.b, args)...)
 
 	defer addMetricSince("update", q, time.Now())
 
 	r.logger.Debugf("QUERY: %s, ARGS: %+v", q, params)
 
-	_, err = r.getDB(ctx).ExecContext(ctx, q, params...)
+	_, err = r.b.exec(ctx, q, params...)
 
 	return err
 }
 
 func (r *Repo) Delete(ctx context.Context, f *Filter) error {
-	wq, args, err := f.WhereQuery()
+	o, err := r.queryOpts(ctx, f.usesTrgm())
+	if err != nil {
+		return err
+	}
+
+	wq, args, err := f.prepare(o).WhereQuery()
 	if err != nil {
 		return err
 	}
+	if err := f.unfiltered(wq); err != nil {
+		return err
+	}
 
 	q := fmt.Sprintf("DELETE FROM %s%s", r.table, wq)
 
@@ -136,11 +209,69 @@ func (r *Repo) Delete(ctx context.Context, f *Filter) error {
 
 	r.logger.Debugf("QUERY: %s, ARGS: %+v", q, args)
 
-	_, err = r.getDB(ctx).ExecContext(ctx, q, args...)
+	_, err = r.b.exec(ctx, q, filterArgs(r.b, args)...)
 
 	return err
 }
 
+// queryOpts are Repo settings applied to filters and sortings on query building
+type queryOpts struct {
+	searchConfig string
+	trgm         bool // pg_trgm extension is installed
+}
+
+// extension is checked only if trgm is used by query
+func (r *Repo) queryOpts(ctx context.Context, trgmUsed bool) (queryOpts, error) {
+	o := queryOpts{searchConfig: r.searchConfig, trgm: true}
+	if !trgmUsed {
+		return o, nil
+	}
+
+	ok, err := r.hasExtension(ctx, "pg_trgm")
+	if err != nil {
+		return o, err
+	}
+	o.trgm = ok
+
+	return o, nil
+}
+
+// missing extension is checked again after this period (it can be created at runtime)
+const extensionRecheck = time.Minute
+
+type extension struct {
+	installed bool
+	checked   time.Time
+}
+
+// hasExtension checks that extension is installed, result is cached
+// (result of missing extension for extensionRecheck period)
+func (r *Repo) hasExtension(ctx context.Context, name string) (bool, error) {
+	r.extMu.Lock()
+	defer r.extMu.Unlock()
+
+	cached, found := r.extensions[name]
+	if found && (cached.installed || time.Since(cached.checked) < extensionRecheck) {
+		return cached.installed, nil
+	}
+
+	var ok bool
+	err := r.queryRow(ctx, "SELECT EXISTS(SELECT 1 FROM pg_extension WHERE extname = $1)", []interface{}{name}, &ok)
+	if err != nil {
+		return false, err
+	}
+
+	if r.extensions == nil {
+		r.extensions = map[string]extension{}
+	}
+	r.extensions[name] = extension{installed: ok, checked: time.Now()}
+	if !ok && !found {
+		r.logger.Infof("%s extension is not installed, fallback is used", name)
+	}
+
+	return ok, nil
+}
+
 func (r *Repo) FindByID(ctx context.Context, id interface{}) *repoQ {
 	q := &repoQ{r: r, ctx: ctx}
 	return q.Where(NewFilter().Eq("id", id))
@@ -154,43 +285,75 @@ func (r *Repo) SelectCustom(ctx context.Context, query string) *repoQ {
 	return &repoQ{r: r, query: query, ctx: ctx}
 }
 
+// SelectFields selects and scans only listed fields (see repoQ.Project)
 func (r *Repo) SelectFields(ctx context.Context, fields ...string) *repoQ {
-	return &repoQ{r: r, query: r.selectQuery("", fields), ctx: ctx}
+	return r.Select(ctx).Project(fields...)
 }
 
 func (r *Repo) SelectQuery() string {
 	return r.selectQuery("", nil)
 }
 
+// Union combines queries results without duplicates.
+// Pagination and sorting of each query are applied to its own results only,
+// Paginate/OrderBy/Count of returned query are applied to combined results.
 func (r *Repo) Union(ctx context.Context, queries ...*repoQ) *repoQ {
-	return &repoQ{r: r, ctx: ctx, unionQueries: queries}
+	return &repoQ{r: r, ctx: ctx, unionQueries: queries, setOp: "UNION"}
+}
+
+// UnionAll is the same as Union, but keeps duplicates
+func (r *Repo) UnionAll(ctx context.Context, queries ...*repoQ) *repoQ {
+	return &repoQ{r: r, ctx: ctx, unionQueries: queries, setOp: "UNION ALL"}
+}
+
+// Intersect returns rows found in results of all queries (see Union)
+func (r *Repo) Intersect(ctx context.Context, queries ...*repoQ) *repoQ {
+	return &repoQ{r: r, ctx: ctx, unionQueries: queries, setOp: "INTERSECT"}
+}
+
+// Except returns rows of first query not found in results of next queries (see Union)
+func (r *Repo) Except(ctx context.Context, queries ...*repoQ) *repoQ {
+	return &repoQ{r: r, ctx: ctx, unionQueries: queries, setOp: "EXCEPT"}
 }
 
 func (r *Repo) selectQuery(alias string, reqFields []string) string {
+	return r.selectQueryExprs(alias, reqFields, nil)
+}
+
+// exprs overrides select expressions of fields, extra expressions are selected after fields
+func (r *Repo) selectQueryExprs(alias string, reqFields []string, exprs map[string]string, extra ...string) string {
 	var fields []string
 	al := alias
 	if al == "" {
 		al = r.table
 	}
-	table := r.table
-	if alias != "" {
-		table += " AS " + alias
-	}
+	table := r.tableRef(alias)
 
 	if reqFields == nil {
 		reqFields = r.fields
 	}
 
 	for _, f := range reqFields {
+		if expr, ok := exprs[f]; ok {
+			fields = append(fields, expr)
+			continue
+		}
 		fields = append(fields, fmt.Sprintf("%s.%s", al, f))
 	}
+	fields = append(fields, extra...)
 
 	return fmt.Sprintf("SELECT %s FROM %s ", strings.Join(fields, ","), table)
 }
 
-func insertQ(table string, obj Model) (string, []interface{}) {
-	m := parseProtoMsg(obj)
-	paramNames, paramValues := toSqlParams(m)
+func (r *Repo) tableRef(alias string) string {
+	if alias == "" {
+		return r.table
+	}
+	return r.table + " AS " + alias
+}
+
+func insertQ(c Codec, table string, obj Model, skip ...string) (string, []interface{}) {
+	paramNames, paramValues := modelParams(c, obj, skip...)
 
 	var placeholders []string
 	for i := 0; i < len(paramNames); i++ {
@@ -205,9 +368,8 @@ func insertQ(table string, obj Model) (string, []interface{}) {
 	), paramValues
 }
 
-func updateQ(table string, obj Model, pkField string) (string, []interface{}) {
-	m := parseProtoMsg(obj)
-	paramNames, paramValues := toSqlParams(m)
+func updateQ(c Codec, table string, obj Model, pkField string, skip ...string) (string, []interface{}) {
+	paramNames, paramValues := modelParams(c, obj, skip...)
 
 	var (
 		placeholders []string
@@ -231,6 +393,10 @@ func updateQ(table string, obj Model, pkField string) (string, []interface{}) {
 }
 
 func objFields(obj Model) []string {
+	if g, ok := obj.(GeneratedModel); ok {
+		return g.SQLColumns()
+	}
+
 	m := parseProtoMsg(obj)
 	var fields []string
 	for _, field := range m {
@@ -240,49 +406,30 @@ func objFields(obj Model) []string {
 	return fields
 }
 
-type dbExec interface {
-	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
-	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
-	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
-}
-
-func (r *Repo) getDB(ctx context.Context) dbExec {
-	v := ctx.Value("_dbtx_")
-	if v == nil {
-		return r.db
-	}
-
-	tx, ok := v.(*sql.Tx)
-	if !ok {
-		panic("invalid TX type ?!")
-	}
-
-	return tx
-}
-
 func (r *Repo) Transaction(ctx context.Context, txFunc func(context.Context) error) error {
 	// check already opened transaction
-	_, ok := ctx.Value("_dbtx_").(*sql.Tx)
-	if ok {
+	inTx, err := r.b.inTx(ctx)
+	if err != nil {
+		return err
+	}
+	if inTx {
 		return txFunc(ctx)
 	}
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	ctx, tx, err := r.b.begin(ctx)
 	if err != nil {
 		return err
 	}
 
-	ctx = context.WithValue(ctx, "_dbtx_", tx)
-
 	err = txFunc(ctx)
 
 	if err != nil {
-		if rerr := tx.Rollback(); rerr != nil {
+		if rerr := tx.rollback(); rerr != nil {
 			r.logger.Errorf("rollback tx failed: %s", rerr)
 		}
 
 		return err
 	}
 
-	return tx.Commit()
+	return tx.commit()
 }
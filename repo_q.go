@@ -18,6 +18,7 @@ type repoQ struct {
 	r            *Repo
 	ctx          context.Context
 	unionQueries []*repoQ // for UNION query
+	setOp        string   // UNION, UNION ALL, INTERSECT or EXCEPT
 
 	globalSearchRules []SearchRule
 	globalSearchTerm  string
@@ -30,21 +31,45 @@ type repoQ struct {
 	pager   Pager
 	joins   []join
 	groupBy []string
+
+	headlines map[string]headline // field -> ts_headline
+	preloads  []string            // relation fields loaded after fetch
+
+	having      *Filter
+	selectExprs []string // select list instead of model columns (see Aggregate)
+
+	distinct   bool
+	distinctOn []string
+
+	fields []string // projection, nil means all fields (see Project)
+	err    error    // invalid query, returned on execution
+
+	ctes []cte // common table expressions (see With)
 }
 
 type SearchRule struct {
 	Table string
 	On    string
 	Query string
+
+	// column matched by trigram similarity to search term (joined with Query by OR),
+	// ILIKE is used if pg_trgm is not installed
+	SimilarTo string
 }
 
 type join struct {
 	jtype string
 	table string
 	bindQ string
+
+	lateral *repoQ      // subquery joined as table (see LateralJoin)
+	nested  *nestedJoin // joined Repo scanned into nested message (see LeftJoinInto)
 }
 
 func (j join) String() string {
+	if j.jtype == "CROSS" {
+		return fmt.Sprintf("CROSS JOIN %s ", j.table)
+	}
 	return fmt.Sprintf("%s JOIN %s ON %s ", j.jtype, j.table, j.bindQ)
 }
 
@@ -84,7 +109,7 @@ func (q *repoQ) Lock() *repoQ {
 }
 
 func (q *repoQ) LeftJoin(table, bindQ string) *repoQ {
-	q.joins = append(q.joins, join{"LEFT", table, bindQ})
+	q.joins = append(q.joins, join{jtype: "LEFT", table: table, bindQ: bindQ})
 	return q
 }
 
@@ -94,6 +119,34 @@ func (q *repoQ) WithGlobalSearch(rules []SearchRule, term string) *repoQ {
 	return q
 }
 
+func (q *repoQ) queryOpts() (queryOpts, error) {
+	return q.r.queryOpts(q.ctx, q.usesTrgm())
+}
+
+func (q *repoQ) usesTrgm() bool {
+	if q.filter.usesTrgm() {
+		return true
+	}
+
+	if s, ok := q.sorting.(*Sorting); ok && s.similar != nil {
+		return true
+	}
+
+	for _, rule := range q.globalSearchRules {
+		if rule.SimilarTo != "" {
+			return true
+		}
+	}
+
+	for _, u := range q.unionQueries {
+		if u.usesTrgm() {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (q *repoQ) FetchOne(o Model) error {
 	rows, err := q.exec()
 	if err != nil {
@@ -105,15 +158,22 @@ func (q *repoQ) FetchOne(o Model) error {
 		return ErrNotFound
 	}
 
-	if err := scanObj(rows, o); err != nil {
+	nested := q.nestedJoins()
+	plan, err := q.r.scanPlan(rows, o, nested)
+	if err != nil {
+		return err
+	}
+
+	if err := scanObj(q.r.b, rows, o, plan, nested...); err != nil {
 		return err
 	}
 
 	if err := rows.Err(); err != nil {
 		return err
 	}
+	rows.Close()
 
-	return nil
+	return q.preload([]reflect.Value{reflect.ValueOf(o)})
 }
 
 func (q *repoQ) Fetch(o interface{}) error {
@@ -122,15 +182,55 @@ func (q *repoQ) Fetch(o interface{}) error {
 		return err
 	}
 
-	return scanObjects(rows, o)
+	if err := q.r.scanObjects(rows, o, q.nestedJoins()...); err != nil {
+		return err
+	}
+
+	if len(q.preloads) == 0 {
+		return nil
+	}
+
+	lst := reflect.ValueOf(o).Elem()
+	objs := make([]reflect.Value, lst.Len())
+	for i := range objs {
+		objs[i] = lst.Index(i)
+	}
+
+	return q.preload(objs)
 }
 
 func (q *repoQ) buildQ(startIdx int, rawFilter string, pager Pager) (string, []interface{}, error) {
-	wq, args, err := q.filter.toQuery(startIdx, "AND")
+	if q.err != nil {
+		return "", nil, q.err
+	}
+
+	if len(q.ctes) > 0 {
+		with, withArgs, err := q.withClause(startIdx)
+		if err != nil {
+			return "", nil, err
+		}
+
+		body := *q
+		body.ctes = nil
+		req, args, err := body.buildQ(startIdx+len(withArgs), rawFilter, pager)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return with + req, append(withArgs, args...), nil
+	}
+
+	o, err := q.queryOpts()
 	if err != nil {
 		return "", nil, err
 	}
 
+	wq, args, err := q.filter.prepare(o).toQuery(startIdx, "AND")
+	if err != nil {
+		return "", nil, err
+	}
+	args = filterArgs(q.r.b, args)
+
 	if rawFilter != "" {
 		if wq == "" {
 			wq = rawFilter
@@ -147,31 +247,83 @@ func (q *repoQ) buildQ(startIdx int, rawFilter string, pager Pager) (string, []i
 		wq += fmt.Sprintf(" GROUP BY %s", strings.Join(q.groupBy, ","))
 	}
 
+	if q.having != nil {
+		hq, hArgs, err := q.having.prepare(o).toQuery(startIdx+len(args), "AND")
+		if err != nil {
+			return "", nil, err
+		}
+		if hq != "" {
+			wq += fmt.Sprintf(" HAVING %s ", hq)
+			args = append(args, filterArgs(q.r.b, hArgs)...)
+		}
+	}
+
+	distinct := q.distinctClause()
+	if distinct != "" && q.lock {
+		return "", nil, errDistinctLock
+	}
+
+	var outerSort string
 	if q.sorting != nil {
-		wq += sortQuery(newSorting(q.sorting))
+		s := newSorting(q.sorting)
+		sq, sortArgs, err := sortQuery(s, startIdx+len(args), o)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(q.distinctOn) > 0 && distinct != "" && s != nil {
+			sq, outerSort = distinctSort(q.distinctOn, s, sq)
+		}
+		wq += sq
+		args = append(args, sortArgs...)
 	}
 
-	if pager != nil {
+	if pager != nil && outerSort == "" {
 		wq += pageQuery(pager)
 	}
 
 	baseQuery := q.query
-	if baseQuery == "" {
-		baseQuery = q.r.selectQuery(q.alias, nil)
+	switch {
+	case len(q.selectExprs) > 0:
+		baseQuery = fmt.Sprintf("SELECT %s FROM %s ", strings.Join(q.selectExprs, ","), q.r.tableRef(q.alias))
+	case baseQuery == "":
+		exprs, hArgs, err := q.headlineExprs(startIdx + len(args))
+		if err != nil {
+			return "", nil, err
+		}
+		fields, nestedExprs := q.nestedSelect()
+		baseQuery = q.r.selectQueryExprs(q.alias, fields, exprs, nestedExprs...)
+		args = append(args, hArgs...)
 	}
 
 	for _, j := range q.joins {
-		baseQuery += j.String()
+		jq, jArgs, err := j.query(startIdx + len(args))
+		if err != nil {
+			return "", nil, err
+		}
+		baseQuery += jq
+		args = append(args, jArgs...)
 	}
 
 	if q.lock {
 		wq += " FOR UPDATE"
 	}
 
-	return baseQuery + wq, args, nil
+	req := baseQuery + wq
+	if distinct != "" {
+		n := len(selectPrefixRe.FindString(req))
+		req = req[:n] + distinct + req[n:]
+	}
+	if outerSort != "" {
+		req = fmt.Sprintf("SELECT * FROM (%s) AS distinct_q%s", req, outerSort)
+		if pager != nil {
+			req += pageQuery(pager)
+		}
+	}
+
+	return req, args, nil
 }
 
-func (q *repoQ) globalSearchExec() (*sql.Rows, error) {
+func (q *repoQ) globalSearchExec() (rows, error) {
 	var args []interface{}
 	var subQueries []string
 
@@ -189,13 +341,18 @@ func (q *repoQ) globalSearchExec() (*sql.Rows, error) {
 	subQueries = append(subQueries, "("+idQ+")")
 	args = append(args, subArgs...)
 
+	o, err := q.queryOpts()
+	if err != nil {
+		return nil, err
+	}
+
 	for _, rule := range q.globalSearchRules {
 		nextQ, _, err := q.buildQ(3, rule.On, pager)
 		if err != nil {
 			return nil, err
 		}
 
-		subQ := fmt.Sprintf("(SELECT main.* FROM %s CROSS JOIN LATERAL (%s) main WHERE %s)", rule.Table, nextQ, rule.Query)
+		subQ := fmt.Sprintf("(SELECT main.* FROM %s CROSS JOIN LATERAL (%s) main WHERE %s)", rule.Table, nextQ, searchRuleQuery(rule, o))
 		subQueries = append(subQueries, subQ)
 	}
 
@@ -209,7 +366,16 @@ func (q *repoQ) globalSearchExec() (*sql.Rows, error) {
 	}
 
 	if q.sorting != nil {
-		uq += sortQuery(newSorting(q.sorting))
+		o, err := q.queryOpts()
+		if err != nil {
+			return nil, err
+		}
+		sq, sortArgs, err := sortQuery(newSorting(q.sorting), len(args)+1, o)
+		if err != nil {
+			return nil, err
+		}
+		uq += sq
+		args = append(args, sortArgs...)
 	}
 
 	if q.pager != nil {
@@ -220,7 +386,7 @@ func (q *repoQ) globalSearchExec() (*sql.Rows, error) {
 
 	q.r.logger.Debugf("QUERY: %s, ARGS: %+v", uq, args)
 
-	rows, err := q.r.getDB(q.ctx).QueryContext(q.ctx, uq, args...)
+	rows, err := q.r.b.query(q.ctx, uq, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -228,7 +394,7 @@ func (q *repoQ) globalSearchExec() (*sql.Rows, error) {
 	return rows, nil
 }
 
-func (q *repoQ) exec() (*sql.Rows, error) {
+func (q *repoQ) exec() (rows, error) {
 	if len(q.unionQueries) > 0 {
 		return q.execUnion()
 	}
@@ -246,7 +412,7 @@ func (q *repoQ) exec() (*sql.Rows, error) {
 
 	q.r.logger.Debugf("QUERY: %s, ARGS: %+v", req, args)
 
-	rows, err := q.r.getDB(q.ctx).QueryContext(q.ctx, req, args...)
+	rows, err := q.r.b.query(q.ctx, req, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -254,14 +420,52 @@ func (q *repoQ) exec() (*sql.Rows, error) {
 	return rows, nil
 }
 
-func (q *repoQ) execUnion() (*sql.Rows, error) {
+func (q *repoQ) execUnion() (rows, error) {
+	uq, args, err := q.unionQ()
+	if err != nil {
+		return nil, err
+	}
+
+	if q.sorting != nil {
+		o, err := q.queryOpts()
+		if err != nil {
+			return nil, err
+		}
+		sq, sortArgs, err := sortQuery(newSorting(q.sorting), len(args)+1, o)
+		if err != nil {
+			return nil, err
+		}
+		uq += sq
+		args = append(args, sortArgs...)
+	}
+
+	if q.pager != nil {
+		uq += pageQuery(q.pager)
+	}
+
+	defer addMetricSince("select", uq, time.Now())
+
+	q.r.logger.Debugf("QUERY: %s, ARGS: %+v", uq, args)
+
+	rows, err := q.r.b.query(q.ctx, uq, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+
+}
+
+// unionQ returns combined query without outer sorting and pagination,
+// each subquery is built with its own pager
+func (q *repoQ) unionQ() (string, []interface{}, error) {
 	idx := 1
 	var args []interface{}
 	var subQueries []string
 	for _, u := range q.unionQueries {
-		subQ, subArgs, err := u.buildQ(idx, "", q.pager)
+		subQ, subArgs, err := u.buildQ(idx, "", u.pager)
 		if err != nil {
-			return nil, err
+			return "", nil, err
 		}
 
 		subQueries = append(subQueries, fmt.Sprintf("( %s )", subQ))
@@ -270,37 +474,24 @@ func (q *repoQ) execUnion() (*sql.Rows, error) {
 		idx += len(subArgs)
 	}
 
+	op := q.setOp
+	if op == "" {
+		op = "UNION"
+	}
+
 	uq := fmt.Sprintf(
 		"WITH combined_results AS (%s) SELECT * FROM combined_results",
-		strings.Join(subQueries, " UNION "),
+		strings.Join(subQueries, " "+op+" "),
 	)
 
 	if len(q.groupBy) > 0 {
 		uq += fmt.Sprintf(" GROUP BY %s", strings.Join(q.groupBy, ","))
 	}
 
-	if q.sorting != nil {
-		uq += sortQuery(newSorting(q.sorting))
-	}
-
-	if q.pager != nil {
-		uq += pageQuery(q.pager)
-	}
-
-	defer addMetricSince("select", uq, time.Now())
-
-	q.r.logger.Debugf("QUERY: %s, ARGS: %+v", uq, args)
-
-	rows, err := q.r.getDB(q.ctx).QueryContext(q.ctx, uq, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	return rows, nil
-
+	return uq, args, nil
 }
 
-func scanObjects(rows *sql.Rows, o interface{}) error {
+func (r *Repo) scanObjects(rows rows, o interface{}, nested ...nestedJoin) error {
 	defer rows.Close()
 
 	if reflect.TypeOf(o).Kind() != reflect.Ptr {
@@ -317,6 +508,7 @@ func scanObjects(rows *sql.Rows, o interface{}) error {
 		return fmt.Errorf("slice element should be a pointer for scan")
 	}
 
+	var plan *scanPlan
 	for rows.Next() {
 		obj := reflect.New(oType.Elem())
 		oi, ok := obj.Interface().(Model)
@@ -325,7 +517,15 @@ func scanObjects(rows *sql.Rows, o interface{}) error {
 			return fmt.Errorf("invalid message type")
 		}
 
-		if err := scanObj(rows, oi); err != nil {
+		if plan == nil {
+			p, err := r.scanPlan(rows, oi, nested)
+			if err != nil {
+				return err
+			}
+			plan = p
+		}
+
+		if err := scanObj(r.b, rows, oi, plan, nested...); err != nil {
 			return err
 		}
 
@@ -342,10 +542,83 @@ type scanner interface {
 	Scan(dest ...interface{}) error
 }
 
-func scanObj(s scanner, obj Model) error {
+// scanObj scans row into obj and nested join fields by columns plan
+func scanObj(c Codec, s scanner, obj Model, plan *scanPlan, nested ...nestedJoin) error {
+	_, dest, err := fieldsDest(c, obj, false)
+	if err != nil {
+		return err
+	}
+
+	nd := make([]*nestedDest, len(nested))
+	for i, n := range nested {
+		if nd[i], err = n.scanDest(c, obj); err != nil {
+			return err
+		}
+	}
+
+	row := make([]interface{}, len(plan.targets))
+	for i, t := range plan.targets {
+		switch {
+		case t.nested < 0:
+			var discard interface{}
+			row[i] = &discard
+		case t.nested == 0:
+			row[i] = dest[t.field]
+		case t.field < 0:
+			row[i] = &nd[t.nested-1].found
+		default:
+			row[i] = nd[t.nested-1].dest[t.field]
+		}
+	}
+
+	if err := s.Scan(row...); err != nil {
+		return err
+	}
+
+	for _, d := range nd {
+		d.set()
+	}
+
+	return nil
+}
+
+// scanDest returns scan destinations of selected fields (nil means all fields except skipped),
+// nullable destinations accept NULL for scalar fields (for left joined models)
+func scanDest(c Codec, obj Model, fields, skip []string, nullable bool) ([]interface{}, error) {
+	names, dest, err := fieldsDest(c, obj, nullable)
+	if err != nil {
+		return nil, err
+	}
+
+	if fields == nil {
+		_, dest = skipColumns(names, dest, skip)
+		return dest, nil
+	}
+
+	ret := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		i := indexOf(names, f)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown field '%s' of %T", f, obj)
+		}
+		ret = append(ret, dest[i])
+	}
+
+	return ret, nil
+}
+
+// fieldsDest returns names and scan destinations of all obj fields
+func fieldsDest(c Codec, obj Model, nullable bool) ([]string, []interface{}, error) {
+	if g, ok := obj.(GeneratedModel); ok && !nullable {
+		return g.SQLColumns(), g.SQLScanDest(c), nil
+	}
+
 	m := parseProtoMsg(obj)
 
-	var dest []interface{}
+	var (
+		names []string
+		dest  []interface{}
+	)
 	for _, f := range m {
 		var v interface{}
 
@@ -353,15 +626,15 @@ func scanObj(s scanner, obj Model) error {
 		case timeIface:
 			t, ok := f.val.Addr().Interface().(**timestamppb.Timestamp)
 			if !ok {
-				return fmt.Errorf("invalid Timestamp type")
+				return nil, nil, fmt.Errorf("invalid Timestamp type")
 			}
 			v = &timeScanner{t}
 		case durationIface:
 			d, ok := f.val.Addr().Interface().(**durationpb.Duration)
 			if !ok {
-				return fmt.Errorf("invalid Duration type")
+				return nil, nil, fmt.Errorf("invalid Duration type")
 			}
-			v = &durationScanner{d}
+			v = c.DurationDest(d)
 		default:
 			switch f.val.Kind() {
 			case reflect.Ptr, reflect.Map:
@@ -374,18 +647,23 @@ func scanObj(s scanner, obj Model) error {
 					case []byte:
 						v = f.val.Addr().Interface()
 					default:
-						v = &arrayScanner{f.val.Addr().Interface()}
+						v = c.ArrayDest(f.val.Addr().Interface())
 					}
 				}
 			default:
 				v = f.val.Addr().Interface()
 			}
+
+			if _, ok := v.(sql.Scanner); !ok && nullable && f.val.Kind() != reflect.Slice {
+				v = &nullScanner{v}
+			}
 		}
 
+		names = append(names, f.name)
 		dest = append(dest, v)
 	}
 
-	return s.Scan(dest...)
+	return names, dest, nil
 }
 
 type timeScanner struct {
@@ -5,14 +5,19 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/lib/pq"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -166,12 +171,57 @@ func updateTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
 	}
 }
 
+func deleteTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+
+	var id *int64
+	err := r.Delete(context.Background(), NewFilter().Eq("id", id))
+	if !errors.Is(err, ErrUnfiltered) {
+		t.Errorf("Delete() without conditions: %v", err)
+	}
+	err = r.Update(context.Background(), testModel, nil)
+	if !errors.Is(err, ErrUnfiltered) {
+		t.Errorf("Update() without conditions: %v", err)
+	}
+
+	mock.ExpectExec(`^DELETE FROM xxx_table WHERE id = \$1$`).WithArgs(22).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`^DELETE FROM xxx_table$`).WithArgs().WillReturnResult(sqlmock.NewResult(0, 3))
+
+	if err := r.Delete(context.Background(), NewFilter().Eq("id", 22)); err != nil {
+		t.Errorf("Delete() failed: %s", err)
+	}
+	if err := r.Delete(context.Background(), NewFilter().Eq("id", id).AllowAll()); err != nil {
+		t.Errorf("Delete() with AllowAll failed: %s", err)
+	}
+
+	// filter slices are converted by Codec, Exec/ExecBatch args are passed as is
+	mock.ExpectExec(`^DELETE FROM xxx_table WHERE id = ANY\(\$1\)$`).WithArgs("{1,2}").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`^DELETE FROM xxx_table WHERE id = ANY\(\$1\)$`).WithArgs("{3}").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare(`^DELETE FROM xxx_table WHERE id = ANY\(\$1\)$`).
+		ExpectExec().WithArgs("{4}").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := r.Delete(context.Background(), NewFilter().In("id", []int64{1, 2})); err != nil {
+		t.Errorf("Delete() failed: %s", err)
+	}
+	if err := r.Exec(context.Background(), "DELETE FROM xxx_table WHERE id = ANY($1)", pq.Array([]int64{3})); err != nil {
+		t.Errorf("Exec() failed: %s", err)
+	}
+	err = r.ExecBatch(context.Background(), "DELETE FROM xxx_table WHERE id = ANY($1)", [][]interface{}{{pq.Array([]int64{4})}})
+	if err != nil {
+		t.Errorf("ExecBatch() failed: %s", err)
+	}
+	err = r.Exec(context.Background(), "DELETE FROM xxx_table WHERE id = ANY($1)", []int64{5})
+	if err == nil {
+		t.Errorf("Exec() with plain slice should fail")
+	}
+}
+
 func getTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
 	t0 := time.Now().Add(-time.Minute)
 	t1 := time.Now()
 	tags := []string{"test", "model"}
 	rows := sqlmock.NewRows(
-		[]string{"id", "name", "website", "descr", "status", "create_time", "update_time", "online_duration", "count", "nested", "tags", "nested_list", "blob", "old_statuses"},
+		[]string{"id", "name", "website", "description", "status", "create_time", "update_time", "online_duration", "count", "nested", "tags", "nested_list", "blob", "old_statuses"},
 	).AddRow(
 		22, "test", "test.com", "some descr", 1, t0, t1, 10000, 334, `{"num": 123, "name": "some name", "active": true}`, pq.Array(&tags),
 		`[{"num": 12, "name": "Item in nested list", "active": false}]`, []byte(`123`), pq.Array(testModel.OldStatuses),
@@ -201,12 +251,371 @@ func getTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
 	expectEq(t, ret.OldStatuses, testModel.OldStatuses)
 }
 
+func joinTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	t0 := time.Now()
+	rows := sqlmock.NewRows(
+		[]string{"id", "name", "website", "description", "status", "create_time", "update_time", "online_duration", "count", "tags", "nested_list", "blob", "old_statuses",
+			"nested__found", "nested__num", "nested__name", "nested__active"},
+	).AddRow(
+		1, "first", "", "", 1, t0, t0, 0, 5, nil, nil, nil, nil, true, 5, "partner", true,
+	).AddRow(
+		2, "second", "", "", 1, t0, t0, 0, 6, nil, nil, nil, nil, false, nil, nil, nil,
+	)
+
+	mock.ExpectQuery(`^SELECT xxx_table.id,(.+),xxx_table.count,xxx_table.tags,(.+),NOT \(ROW\(nested.\*\) IS NULL\) AS nested__found,`+
+		`nested.num AS nested__num,nested.name AS nested__name,nested.active AS nested__active FROM xxx_table `+
+		`LEFT JOIN partners AS nested ON nested.num = xxx_table.count INNER JOIN owners ON owners.id = xxx_table.id CROSS JOIN tags `+
+		`LEFT JOIN LATERAL \(SELECT (.+) FROM xxx_table AS l WHERE l.id > xxx_table.id AND l.status = \$2 (.+)\) AS l ON true `+
+		`WHERE nested.name = \$1`).WithArgs("partner", 3).WillReturnRows(rows)
+
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+	partners := NewRepo(db, "partners", &NestedModel{}, dummyLogger{})
+
+	var ret []*TestModel
+	err := r.Select(context.Background()).
+		LeftJoinInto("nested", partners, "", "nested.num = xxx_table.count").
+		InnerJoin("owners", "owners.id = xxx_table.id").
+		CrossJoin("tags").
+		LateralJoin(r.Select(context.Background()).As("l").Where(NewFilter().Raw("l.id > xxx_table.id").Eq("l.status", 3)).Paginate(Page(0, 1)), "l").
+		Where(NewFilter().Eq("nested.name", "partner")).
+		Fetch(&ret)
+	if err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+
+	expectEq(t, len(ret), 2)
+	expectEq(t, ret[0].Nested, &NestedModel{Num: 5, Name: "partner", Active: true})
+	expectEq(t, ret[0].Count, int64(5))
+	if ret[1].Nested != nil {
+		t.Errorf("not joined nested model: %+v", ret[1].Nested)
+	}
+
+	for _, field := range []string{"unknown", "nested_list", "name"} {
+		if err := r.Select(context.Background()).LeftJoinInto(field, partners, "", "true").Fetch(&ret); err == nil {
+			t.Errorf("Fetch() should fail on join into field %s", field)
+		}
+	}
+	if err := r.Select(context.Background()).InnerJoinInto("nested", r, "", "true").FetchOne(&TestModel{}); err == nil {
+		t.Error("FetchOne() should fail on join of other model")
+	}
+}
+
+func TestNullScanner(t *testing.T) {
+	var (
+		num  int32
+		unum uint32
+	)
+	if err := (&nullScanner{dest: &num}).Scan(int64(math.MaxInt32)); err != nil {
+		t.Fatalf("Scan() failed: %s", err)
+	}
+	expectEq(t, num, int32(math.MaxInt32))
+	if err := (&nullScanner{dest: &num}).Scan([]byte("-7")); err != nil {
+		t.Fatalf("Scan() failed: %s", err)
+	}
+	expectEq(t, num, int32(-7))
+
+	for _, src := range []interface{}{int64(math.MaxInt32 + 1), int64(math.MinInt32 - 1), "4294967296"} {
+		if err := (&nullScanner{dest: &num}).Scan(src); err == nil {
+			t.Errorf("Scan(%v) into int32 should fail", src)
+		}
+	}
+	expectEq(t, num, int32(-7))
+
+	if err := (&nullScanner{dest: &unum}).Scan(int64(-1)); err == nil {
+		t.Error("Scan(-1) into uint32 should fail")
+	}
+}
+
+func preloadTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	t0 := time.Now()
+	cols := []string{"id", "name", "website", "description", "status", "create_time", "update_time", "online_duration", "count", "tags", "blob", "old_statuses"}
+	mock.ExpectQuery(`^SELECT (.+),xxx_table.count,xxx_table.tags,xxx_table.blob,xxx_table.old_statuses FROM xxx_table`).WillReturnRows(
+		sqlmock.NewRows(cols).
+			AddRow(1, "first", "", "", 1, t0, t0, 0, 7, nil, nil, nil).
+			AddRow(2, "second", "", "", 1, t0, t0, 0, 0, nil, nil, nil),
+	)
+	mock.ExpectQuery(`^SELECT partners.num,partners.name,partners.active,partners.model_id::text AS relation_key FROM partners\s+WHERE partners.model_id = ANY\(\$1\)\s+ORDER BY partners.model_id ASC$`).
+		WithArgs(pq.Array([]int32{1, 2})).
+		WillReturnRows(sqlmock.NewRows([]string{"num", "name", "active", "relation_key"}).
+			AddRow(1, "a", true, "1").
+			AddRow(2, "b", false, "1").
+			AddRow(3, "c", false, "2"))
+	mock.ExpectQuery(`^SELECT (.+),partners.id::text AS relation_key FROM partners\s+WHERE partners.id = ANY\(\$1\)\s+ORDER BY partners.id ASC$`).
+		WithArgs(pq.Array([]int64{7})).
+		WillReturnRows(sqlmock.NewRows([]string{"num", "name", "active", "relation_key"}).AddRow(7, "owner", true, "7"))
+	partners := NewRepo(db, "partners", &NestedModel{}, dummyLogger{})
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{}).
+		HasMany("nested_list", partners, "model_id").
+		BelongsTo("nested", partners, "count")
+
+	var ret []*TestModel
+	err := r.Select(context.Background()).Preload("nested_list", "nested").Fetch(&ret)
+	if err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+	expectEq(t, len(ret), 2)
+	expectEq(t, ret[0].NestedList, []*NestedModel{{Num: 1, Name: "a", Active: true}, {Num: 2, Name: "b"}})
+	expectEq(t, ret[1].NestedList, []*NestedModel{{Num: 3, Name: "c"}})
+	expectEq(t, ret[0].Nested, &NestedModel{Num: 7, Name: "owner", Active: true})
+	if ret[1].Nested != nil {
+		t.Errorf("nested without key: %+v", ret[1].Nested)
+	}
+
+	// nested is a column if it is not a relation
+	mock.ExpectQuery(`^SELECT (.+),xxx_table.count,xxx_table.nested,xxx_table.tags,(.+) FROM xxx_table`).WillReturnRows(
+		sqlmock.NewRows(append(cols, "nested")).AddRow(1, "first", "", "", 1, t0, t0, 0, 7, nil, nil, nil, nil),
+	)
+	mock.ExpectQuery(`^SELECT (.+),model_partners.model_id::text AS relation_key FROM partners\s+` +
+		`INNER JOIN model_partners ON model_partners.partner_id = partners.id\s+WHERE model_partners.model_id = ANY\(\$1\)\s+ORDER BY model_partners.model_id ASC$`).
+		WithArgs(pq.Array([]int32{1})).
+		WillReturnRows(sqlmock.NewRows([]string{"num", "name", "active", "relation_key"}).AddRow(5, "m2m", true, "1"))
+
+	m2m := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{}).
+		ManyToMany("nested_list", partners, "model_partners", "model_id", "partner_id")
+	ret = nil
+	err = m2m.Select(context.Background()).Preload("nested_list").Fetch(&ret)
+	if err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+	expectEq(t, ret[0].NestedList, []*NestedModel{{Num: 5, Name: "m2m", Active: true}})
+
+	// children are ordered by primary key
+	mock.ExpectQuery(`^SELECT parents.id FROM parents`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`^SELECT (.+),xxx_table.count::text AS relation_key FROM xxx_table\s+` +
+		`WHERE xxx_table.count = ANY\(\$1\)\s+ORDER BY xxx_table.count, xxx_table.id ASC$`).
+		WithArgs(pq.Array([]int32{1})).WillReturnRows(sqlmock.NewRows(nil))
+	parents := NewRepo(db, "parents", &testParentModel{}, dummyLogger{}).
+		HasMany("children", NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{}), "count")
+	var parentsRet []*testParentModel
+	if err := parents.Select(context.Background()).Preload("children").Fetch(&parentsRet); err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+
+	// invalid declarations fail validation and preload
+	invalid := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{}).
+		HasMany("nested", partners, "model_id").
+		BelongsTo("nested_list", partners, "unknown")
+	err = invalid.Validate(context.Background())
+	if err == nil {
+		t.Fatal("Validate() should fail on invalid relations")
+	}
+	expectEq(t, err.Error(), "relation nested: field 'nested' of *protosql.TestModel is not []*protosql.NestedModel")
+	mock.ExpectQuery(`^SELECT (.+) FROM xxx_table`).WillReturnRows(
+		sqlmock.NewRows(append(cols, "nested", "nested_list")).AddRow(1, "first", "", "", 1, t0, t0, 0, 7, nil, nil, nil, nil, nil),
+	)
+	err = invalid.Select(context.Background()).Preload("nested_list").Fetch(&ret)
+	if err == nil {
+		t.Fatal("Fetch() should fail on preload of invalid relation")
+	}
+	expectEq(t, err.Error(), "relation nested_list: *protosql.TestModel has no key field 'unknown'")
+}
+
+type testParentModel struct {
+	Id       int32        `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Children []*TestModel `protobuf:"bytes,2,rep,name=children,proto3" json:"children,omitempty"`
+}
+
+func (*testParentModel) Reset()        {}
+func (*testParentModel) ProtoMessage() {}
+
+func aggregateTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	ctx := context.Background()
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+
+	mock.ExpectQuery(`^SELECT count\(\*\) FROM \(SELECT (.+) FROM xxx_table\s+WHERE status = \$1\s+\) AS count_q$`).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+	n, err := r.Select(ctx).Where(NewFilter().Eq("status", 1)).OrderBy(Desc("id")).Paginate(Page(1, 10)).Count()
+	if err != nil {
+		t.Fatalf("Count() failed: %s", err)
+	}
+	expectEq(t, n, int64(42))
+
+	mock.ExpectQuery(`^SELECT EXISTS \(SELECT (.+) FROM xxx_table\s+WHERE id = \$1\s+\)$`).
+		WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	ok, err := r.FindByID(ctx, 5).Exists()
+	if err != nil {
+		t.Fatalf("Exists() failed: %s", err)
+	}
+	expectEq(t, ok, true)
+
+	mock.ExpectQuery(`^SELECT COALESCE\(sum\(count\), 0\) AS sum_count,max\(update_time\) AS last FROM xxx_table\s+WHERE status = \$1$`).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"sum_count", "last"}).AddRow([]byte("100"), nil))
+	var (
+		sum  int64
+		last *time.Time
+	)
+	err = r.Select(ctx).Where(NewFilter().Eq("status", 1)).OrderBy(Desc("id")).
+		Aggregate(Sum("count"), Max("update_time").As("last")).Scan(&sum, &last)
+	if err != nil {
+		t.Fatalf("Aggregate().Scan() failed: %s", err)
+	}
+	expectEq(t, sum, int64(100))
+	if last != nil {
+		t.Errorf("max of empty set: %v", last)
+	}
+
+	groupQ := `^SELECT status,count\(\*\) AS count,avg\(t.count\) AS avg_count FROM xxx_table AS t\s+WHERE name != \$1\s+` +
+		`GROUP BY status HAVING count\(\*\) > \$2\s+ORDER BY count DESC LIMIT 10$`
+	groupRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"status", "count", "avg_count"}).AddRow(1, 5, 2.5).AddRow(2, 3, nil)
+	}
+	q := r.Select(ctx).As("t").Where(NewFilter().Neq("name", "x")).GroupBy("status").
+		Having(NewFilter().Gt("count(*)", 2)).OrderBy(Desc("count")).Paginate(Page(0, 10)).
+		Aggregate(CountOf("*"), Avg("t.count"))
+
+	type statusStats struct {
+		Status TestModelStatus
+		Total  int64    `db:"count"`
+		Avg    *float64 `db:"avg_count"`
+	}
+	var stats []statusStats
+	mock.ExpectQuery(groupQ).WithArgs("x", 2).WillReturnRows(groupRows())
+	if err := q.Fetch(&stats); err != nil {
+		t.Fatalf("Aggregate().Fetch() failed: %s", err)
+	}
+	avg := 2.5
+	expectEq(t, stats, []statusStats{{Status: 1, Total: 5, Avg: &avg}, {Status: 2, Total: 3}})
+
+	var maps []map[string]interface{}
+	mock.ExpectQuery(groupQ).WithArgs("x", 2).WillReturnRows(groupRows())
+	if err := q.Fetch(&maps); err != nil {
+		t.Fatalf("Aggregate().Fetch() failed: %s", err)
+	}
+	expectEq(t, maps, []map[string]interface{}{
+		{"status": int64(1), "count": int64(5), "avg_count": 2.5},
+		{"status": int64(2), "count": int64(3), "avg_count": nil},
+	})
+}
+
+func timeSeriesTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	ctx := context.Background()
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(48 * time.Hour)
+
+	s := r.Select(ctx).Where(NewFilter().Eq("status", 1)).
+		TimeSeries("create_time", "day", CountOf("*"), Sum("count")).
+		Between(from, to).Timezone("Europe/Kyiv")
+
+	req, args, err := s.build()
+	if err != nil {
+		t.Fatalf("build() failed: %s", err)
+	}
+	expectEq(t, req, "WITH data AS (SELECT date_trunc('day', (create_time AT TIME ZONE 'Europe/Kyiv')) AS bucket,"+
+		"count(*) AS count,COALESCE(sum(count), 0) AS sum_count FROM xxx_table  "+
+		"WHERE create_time >= $1 AND create_time <= $2 AND (status = $3)  GROUP BY 1), "+
+		"series AS (SELECT generate_series(date_trunc('day', ($4::timestamptz AT TIME ZONE 'Europe/Kyiv')), "+
+		"date_trunc('day', ($5::timestamptz AT TIME ZONE 'Europe/Kyiv')), interval '1 day') AS bucket) "+
+		"SELECT (series.bucket AT TIME ZONE 'Europe/Kyiv') AS bucket, COALESCE(data.count, 0), COALESCE(data.sum_count, 0) "+
+		"FROM series LEFT JOIN data ON data.bucket = series.bucket ORDER BY series.bucket")
+	expectEq(t, args, []interface{}{from, to, 1, from, to})
+
+	req, _, err = r.Select(ctx).TimeSeries("create_time", "15 minutes", Avg("count")).build()
+	if err != nil {
+		t.Fatalf("build() failed: %s", err)
+	}
+	if !strings.Contains(req, "date_bin('15 minute', create_time, TIMESTAMPTZ '2000-01-03') AS bucket") ||
+		!strings.Contains(req, "generate_series((SELECT min(bucket) FROM data), (SELECT max(bucket) FROM data), interval '15 minute')") {
+		t.Errorf("unexpected time series query: %s", req)
+	}
+
+	for _, interval := range []string{"2 months", "fortnight", "0 days"} {
+		if _, _, err := r.Select(ctx).TimeSeries("create_time", interval, CountOf("*")).build(); err == nil {
+			t.Errorf("interval %q should be invalid", interval)
+		}
+	}
+
+	mock.ExpectQuery(`^WITH data AS`).WillReturnRows(sqlmock.NewRows([]string{"bucket", "count"}).
+		AddRow(from, 3).AddRow(from.Add(time.Hour), 0))
+	buckets, err := r.Select(ctx).TimeSeries("create_time", "hour", CountOf("*")).Fetch()
+	if err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+	expectEq(t, buckets, []TimeBucket{
+		{Start: from, Values: map[string]float64{"count": 3}},
+		{Start: from.Add(time.Hour), Values: map[string]float64{"count": 0}},
+	})
+}
+
+func distinctTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	ctx := context.Background()
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+	sel := r.selectQuery("t", nil)[len("SELECT "):]
+
+	req, _, err := r.Select(ctx).As("t").InnerJoin("tags", "tags.model_id = t.id").Distinct().OrderBy(Asc("t.name")).buildQ(1, "", Page(1, 10))
+	if err != nil {
+		t.Fatalf("buildQ() failed: %s", err)
+	}
+	expectEq(t, req, "SELECT DISTINCT "+sel+"INNER JOIN tags ON tags.model_id = t.id  ORDER BY t.name ASC LIMIT 10 OFFSET 10")
+
+	// latest row per status, sorted by time in outer query
+	req, _, err = r.Select(ctx).As("t").DistinctOn("t.status").OrderBy(Desc("t.create_time")).buildQ(1, "", Page(0, 5))
+	if err != nil {
+		t.Fatalf("buildQ() failed: %s", err)
+	}
+	expectEq(t, req, "SELECT * FROM (SELECT DISTINCT ON (t.status) "+sel+" ORDER BY t.status, t.create_time DESC) AS distinct_q ORDER BY create_time DESC LIMIT 5")
+
+	req, _, err = r.Select(ctx).DistinctOn("status", "name").OrderBy(Desc("name")).buildQ(1, "", nil)
+	if err != nil {
+		t.Fatalf("buildQ() failed: %s", err)
+	}
+	expectEq(t, req, "SELECT DISTINCT ON (status, name) "+r.SelectQuery()[len("SELECT "):]+" ORDER BY status, name DESC")
+
+	if _, _, err := r.Select(ctx).Distinct().Lock().buildQ(1, "", nil); err == nil {
+		t.Error("buildQ() should fail on DISTINCT with FOR UPDATE")
+	}
+
+	req, _, err = r.SelectCustom(ctx, "\n  select\n\tname FROM xxx_table").Distinct().buildQ(1, "", nil)
+	if err != nil {
+		t.Fatalf("buildQ() failed: %s", err)
+	}
+	expectEq(t, req, "\n  select\n\tDISTINCT name FROM xxx_table")
+
+	mock.ExpectQuery(`^SELECT count\(\*\) FROM \(SELECT DISTINCT ON \(status\) (.+) FROM xxx_table\s*\) AS count_q$`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	n, err := r.Select(ctx).DistinctOn("status").OrderBy(Desc("create_time")).Count()
+	if err != nil {
+		t.Fatalf("Count() failed: %s", err)
+	}
+	expectEq(t, n, int64(3))
+}
+
+func projectionTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	ctx := context.Background()
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+
+	mock.ExpectQuery(`^SELECT xxx_table.id,xxx_table.name FROM xxx_table\s+WHERE status = \$1$`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "first").AddRow(2, "second"))
+
+	var ret []*TestModel
+	if err := r.SelectFields(ctx, "name", "id").Where(NewFilter().Eq("status", 1)).Fetch(&ret); err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+	expectEq(t, ret, []*TestModel{{Id: 1, Name: "first"}, {Id: 2, Name: "second"}})
+
+	mock.ExpectQuery(`^SELECT t.status,t.nested FROM xxx_table AS t\s+WHERE t.id = \$1$`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "nested"}).AddRow(2, `{"name": "n"}`))
+
+	var m TestModel
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"nested.name", "status"}}
+	if err := r.Select(ctx).As("t").ReadMask(mask).Where(NewFilter().Eq("t.id", 5)).FetchOne(&m); err != nil {
+		t.Fatalf("FetchOne() failed: %s", err)
+	}
+	expectEq(t, m.Status, ModelStatus_STATUS_ACTIVE)
+	expectEq(t, m.Nested, &NestedModel{Name: "n"})
+	expectEq(t, m.CreateTime, (*timestamppb.Timestamp)(nil))
+
+	if err := r.Select(ctx).Project("unknown").Fetch(&ret); err == nil {
+		t.Error("Fetch() should fail on unknown field")
+	}
+}
+
 func unionTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
 	t0 := time.Now().Add(-time.Minute)
 	t1 := time.Now()
 	tags := []string{"test", "model"}
 	rows := sqlmock.NewRows(
-		[]string{"id", "name", "website", "descr", "status", "create_time", "update_time", "online_duration", "count", "nested", "tags", "nested_list", "blob", "old_statuses"},
+		[]string{"id", "name", "website", "description", "status", "create_time", "update_time", "online_duration", "count", "nested", "tags", "nested_list", "blob", "old_statuses"},
 	).AddRow(
 		22, "test", "test.com", "some descr", 1, t0, t1, 10000, 334, `{"num": 123, "name": "some name", "active": true}`, pq.Array(&tags),
 		`[{"num": 12, "name": "Item in nested list", "active": false}]`, []byte(`123`), pq.Array(testModel.OldStatuses),
@@ -240,12 +649,51 @@ func unionTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
 	expectEq(t, ret.OldStatuses, testModel.OldStatuses)
 }
 
+func setOpTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	ctx := context.Background()
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+	union := func() *repoQ {
+		return r.UnionAll(ctx,
+			r.SelectFields(ctx, "id").Where(NewFilter().Eq("status", 1)).OrderBy(Desc("count")).Paginate(Page(0, 5)),
+			r.SelectFields(ctx, "id").Where(NewFilter().Eq("status", 2)),
+		).OrderBy(Asc("id")).Paginate(Page(2, 10))
+	}
+
+	mock.ExpectQuery(`^WITH combined_results AS \(\( SELECT xxx_table.id FROM xxx_table\s+WHERE status = \$1\s+ORDER BY count DESC LIMIT 5 \) `+
+		`UNION ALL \( SELECT xxx_table.id FROM xxx_table\s+WHERE status = \$2\s+\)\) SELECT \* FROM combined_results ORDER BY id ASC LIMIT 10 OFFSET 20$`).
+		WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21).AddRow(22))
+
+	var ret []*TestModel
+	if err := union().Fetch(&ret); err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+	expectEq(t, ret, []*TestModel{{Id: 21}, {Id: 22}})
+
+	mock.ExpectQuery(`^SELECT count\(\*\) FROM \(WITH combined_results AS \((.+) UNION ALL (.+)\) SELECT \* FROM combined_results\) AS count_q$`).
+		WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+	n, err := union().Count()
+	if err != nil {
+		t.Fatalf("Count() failed: %s", err)
+	}
+	expectEq(t, n, int64(7))
+
+	mock.ExpectQuery(`^WITH combined_results AS \(\( SELECT (.+) \) EXCEPT \( SELECT (.+) \)\) SELECT \* FROM combined_results$`).
+		WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	var except []*TestModel
+	if err := r.Except(ctx, r.Select(ctx).Where(NewFilter().Eq("status", 1)), r.Select(ctx).Where(NewFilter().Eq("status", 2))).Fetch(&except); err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+	expectEq(t, except, []*TestModel{{Id: 3}})
+}
+
 func filterTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
 	t0 := time.Now().Add(-time.Minute)
 	t1 := time.Now()
 	tags := []string{"test", "model"}
 	rows := sqlmock.NewRows(
-		[]string{"id", "name", "website", "descr", "status", "create_time", "update_time", "online_duration", "count", "nested", "tags", "nested_list", "blob", "old_statuses"},
+		[]string{"id", "name", "website", "description", "status", "create_time", "update_time", "online_duration", "count", "nested", "tags", "nested_list", "blob", "old_statuses"},
 	).AddRow(
 		22, "test", "test.com", "some descr", 1, t0, t1, 10000, 334, `{"num": 123, "name": "some name", "active": true}`, pq.Array(&tags),
 		`[{"num": 12, "name": "Item in nested list", "active": false}]`, []byte(`123`), pq.Array(testModel.OldStatuses),
@@ -314,14 +762,218 @@ func expectEq(t *testing.T, v1, v2 interface{}) {
 	}
 }
 
+func scanPlanTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	ctx := context.Background()
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+	query := "SELECT t.name, t.id, o.name AS owner, t.description FROM xxx_table AS t JOIN owners AS o ON o.id = t.id"
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`^SELECT t.name, t.id, o.name AS owner`).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "id", "owner", "description"}).AddRow("first", 1, "x", "d").AddRow("second", 2, "y", ""))
+
+		var ret []*TestModel
+		if err := r.SelectCustom(ctx, query).Fetch(&ret); err != nil {
+			t.Fatalf("Fetch() failed: %s", err)
+		}
+		expectEq(t, ret, []*TestModel{{Id: 1, Name: "first", Description: "d"}, {Id: 2, Name: "second"}})
+	}
+
+	// all columns in other order with one unknown column are still scanned by name
+	reordered := []string{"old_statuses", "blob", "nested_list", "tags", "nested", "count", "online_duration",
+		"update_time", "create_time", "status", "summary", "website", "name", "id"}
+	t0 := time.Now()
+	mock.ExpectQuery(`^SELECT old_statuses`).WillReturnRows(sqlmock.NewRows(reordered).
+		AddRow(nil, nil, nil, nil, nil, 5, 0, t0, t0, 2, "x", "site", "first", 1))
+
+	var m TestModel
+	if err := r.SelectCustom(ctx, "SELECT old_statuses, ...").FetchOne(&m); err != nil {
+		t.Fatalf("FetchOne() failed: %s", err)
+	}
+	expectEq(t, m.Id, int32(1))
+	expectEq(t, m.Name, "first")
+	expectEq(t, m.Website, "site")
+	expectEq(t, m.Description, "")
+	expectEq(t, m.Count, int64(5))
+
+	strictRe := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{}, WithStrictScan())
+	mock.ExpectQuery(`^SELECT old_statuses`).WillReturnRows(sqlmock.NewRows(reordered).
+		AddRow(nil, nil, nil, nil, nil, 5, 0, t0, t0, 2, "x", "site", "first", 1))
+	err := strictRe.SelectCustom(ctx, "SELECT old_statuses, ...").FetchOne(&m)
+	if err == nil || !strings.Contains(err.Error(), "unknown column 'summary'") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// plans cache is bounded
+	for i := 0; i < maxScanPlans+10; i++ {
+		mock.ExpectQuery(`^SELECT id`).WillReturnRows(sqlmock.NewRows([]string{"id", fmt.Sprintf("c%d", i)}).AddRow(i, "x"))
+
+		var ret []*TestModel
+		if err := r.SelectCustom(ctx, "SELECT id, ...").Fetch(&ret); err != nil {
+			t.Fatalf("Fetch() failed: %s", err)
+		}
+		expectEq(t, ret, []*TestModel{{Id: int32(i)}})
+	}
+	if len(r.plans) > maxScanPlans {
+		t.Errorf("scan plans cache is not bounded: %d", len(r.plans))
+	}
+
+	strict := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{}, WithStrictScan())
+	mock.ExpectQuery(`^SELECT t.name, t.id, o.name AS owner`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "id", "owner"}).AddRow("first", 1, "x"))
+
+	err = strict.SelectCustom(ctx, query).FetchOne(&m)
+	if err == nil || !strings.Contains(err.Error(), "unknown column 'owner'") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func cteTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	ctx := context.Background()
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+
+	mock.ExpectQuery(`^WITH active AS \(SELECT xxx_table.id FROM xxx_table\s+WHERE status = \$1\s*\) `+
+		`SELECT xxx_table.id,xxx_table.name FROM xxx_table\s+WHERE id IN \(SELECT id FROM active\) AND name = \$2$`).
+		WithArgs(1, "x").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "x"))
+
+	var ret []*TestModel
+	err := r.SelectFields(ctx, "id", "name").
+		With("active", r.SelectFields(ctx, "id").Where(NewFilter().Eq("status", 1))).
+		Where(NewFilter().Raw("id IN (SELECT id FROM active)").Eq("name", "x")).
+		Fetch(&ret)
+	if err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+	expectEq(t, ret, []*TestModel{{Id: 1, Name: "x"}})
+}
+
+func treeTest(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+	ctx := context.Background()
+	r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{})
+	t0 := time.Now()
+	cols := append(strings.Split("id,name,website,description,status,create_time,update_time,online_duration,count,nested,tags,nested_list,blob,old_statuses", ","),
+		"tree_depth", "tree_path")
+
+	mock.ExpectQuery(`^WITH RECURSIVE tree AS \(SELECT t.\*, 0 AS tree_depth, ARRAY\[t.id::text\] AS tree_path FROM xxx_table AS t WHERE id = \$1 `+
+		`UNION ALL SELECT t.\*, tree.tree_depth \+ 1, tree.tree_path \|\| t.id::text FROM xxx_table AS t JOIN tree ON t.count = tree.id `+
+		`WHERE NOT t.id::text = ANY\(tree.tree_path\) AND tree.tree_depth < 2\) `+
+		`SELECT tree.id,(.+),tree.old_statuses,tree.tree_depth,tree.tree_path FROM tree\s+WHERE status = \$2\s+ORDER BY tree_path ASC$`).
+		WithArgs(1, 1).WillReturnRows(sqlmock.NewRows(cols).
+		AddRow(1, "root", "", "", 1, t0, t0, 0, 0, nil, nil, nil, nil, nil, 0, "{1}").
+		AddRow(3, "child", "", "", 1, t0, t0, 0, 1, nil, nil, nil, nil, nil, 1, "{1,3}"))
+
+	nodes, err := r.Descendants(ctx, "count", 1).MaxDepth(2).Where(NewFilter().Eq("status", 1)).Fetch()
+	if err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+	expectEq(t, len(nodes), 2)
+	expectEq(t, nodes[1].Model.(*TestModel).Name, "child")
+	expectEq(t, nodes[1].Depth, 1)
+	expectEq(t, nodes[1].Path, []string{"1", "3"})
+
+	mock.ExpectQuery(`^WITH RECURSIVE tree AS \((.+) FROM xxx_table AS t WHERE id = \$1 UNION ALL (.+) JOIN tree ON t.id = tree.count ` +
+		`WHERE NOT t.id::text = ANY\(tree.tree_path\)\) SELECT (.+) FROM tree\s+ORDER BY tree_depth ASC$`).
+		WithArgs(3).WillReturnRows(sqlmock.NewRows(cols))
+
+	nodes, err = r.Ancestors(ctx, "count", 3).Fetch()
+	if err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+	expectEq(t, len(nodes), 0)
+
+	// roots of non-optional parent column have zero value
+	mock.ExpectQuery(`^WITH RECURSIVE tree AS \((.+) FROM xxx_table AS t WHERE \(count IS NULL OR count = \$1\) UNION ALL (.+) JOIN tree ON t.count = tree.id ` +
+		`WHERE NOT t.id::text = ANY\(tree.tree_path\)\) SELECT (.+) FROM tree\s+ORDER BY tree_path ASC$`).
+		WithArgs(int64(0)).WillReturnRows(sqlmock.NewRows(cols).
+		AddRow(1, "root", "", "", 1, t0, t0, 0, 0, nil, nil, nil, nil, nil, 0, "{1}").
+		AddRow(2, "child", "", "", 1, t0, t0, 0, 1, nil, nil, nil, nil, nil, 1, "{1,2}"))
+
+	nodes, err = r.Tree(ctx, "count").Fetch()
+	if err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+	expectEq(t, len(nodes), 2)
+	expectEq(t, nodes[0].Model.(*TestModel).Name, "root")
+	expectEq(t, nodes[1].Path, []string{"1", "2"})
+
+	mock.ExpectQuery(`^WITH RECURSIVE tree AS \((.+) FROM xxx_table AS t WHERE status = \$1 UNION ALL `).
+		WithArgs(2).WillReturnRows(sqlmock.NewRows(cols))
+
+	if _, err := r.Tree(ctx, "count").Roots(NewFilter().Eq("status", 2)).Fetch(); err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+
+	// start condition uses search config and trigram fallback of Repo
+	fr := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{}, WithSearchConfig("english"))
+	mock.ExpectQuery("^SELECT EXISTS").WithArgs("pg_trgm").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`^WITH RECURSIVE tree AS \((.+) FROM xxx_table AS t WHERE to_tsvector\('english', name\) @@ `+
+		`websearch_to_tsquery\('english', \$1\) AND website ILIKE \$2 UNION ALL `).
+		WithArgs("root", "%site%").WillReturnRows(sqlmock.NewRows(cols))
+
+	if _, err := fr.Tree(ctx, "count").Roots(NewFilter().Match("name", "root", "").Similar("website", "site", 0)).Fetch(); err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestRepo(t *testing.T) {
 	t.Run("insert", wrapTest(insertTest))
 	t.Run("updateByID", wrapTest(updateByIDTest))
 	t.Run("update", wrapTest(updateTest))
+	t.Run("delete", wrapTest(deleteTest))
 	t.Run("getbyid", wrapTest(getTest))
 	t.Run("filter", wrapTest(filterTest))
 	t.Run("transaction", wrapTest(txTest))
 	t.Run("union", wrapTest(unionTest))
+	t.Run("setop", wrapTest(setOpTest))
+	t.Run("join", wrapTest(joinTest))
+	t.Run("preload", wrapTest(preloadTest))
+	t.Run("aggregate", wrapTest(aggregateTest))
+	t.Run("timeseries", wrapTest(timeSeriesTest))
+	t.Run("distinct", wrapTest(distinctTest))
+	t.Run("projection", wrapTest(projectionTest))
+	t.Run("scanplan", wrapTest(scanPlanTest))
+	t.Run("cte", wrapTest(cteTest))
+	t.Run("tree", wrapTest(treeTest))
+}
+
+func TestPgxCodec(t *testing.T) {
+	b := &pgxBackend{}
+
+	_, params := insertQ(b, "xxx_table", testModel)
+	expectEq(t, params[10], testModel.Tags)
+	expectEq(t, params[13], testModel.OldStatuses)
+
+	v, _ := params[7].(pgxDuration).Int64Value()
+	expectEq(t, v.Int64, int64(0))
+
+	var d *durationpb.Duration
+	s := b.DurationDest(&d).(*pgxDurationScanner)
+	if err := s.ScanInterval(pgtype.Interval{Microseconds: 1500000, Days: 1, Valid: true}); err != nil {
+		t.Fatalf("ScanInterval() failed: %s", err)
+	}
+	expectEq(t, d.AsDuration(), 24*time.Hour+1500*time.Millisecond)
+
+	if err := s.ScanInt64(pgtype.Int8{Int64: 2500, Valid: true}); err != nil {
+		t.Fatalf("ScanInt64() failed: %s", err)
+	}
+	expectEq(t, d.AsDuration(), 2500*time.Millisecond)
+
+	ctx := context.WithValue(context.Background(), txCtxKey, &sql.Tx{})
+	if _, err := b.exec(ctx, "SELECT 1"); !errors.Is(err, ErrForeignTx) {
+		t.Errorf("exec() in foreign transaction: %v", err)
+	}
+
+	r := newRepo(b, "xxx_table", &TestModel{}, dummyLogger{})
+	err := r.Transaction(ctx, func(context.Context) error {
+		t.Error("transaction func should not be called")
+		return nil
+	})
+	if !errors.Is(err, ErrForeignTx) {
+		t.Errorf("Transaction() in foreign transaction: %v", err)
+	}
 }
 
 // dummy logger
@@ -340,6 +992,9 @@ type NestedModel struct {
 	Active bool   `protobuf:"bytes,3,opt,name=active,proto3" json:"active,omitempty"`
 }
 
+func (*NestedModel) Reset()        {}
+func (*NestedModel) ProtoMessage() {}
+
 type TestModel struct {
 	Id             int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	Name           string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
@@ -0,0 +1,184 @@
+package protosql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// rows are scanned by result column names, so select order and extra columns
+// (e.g. custom queries) do not matter. If no column name matches model fields,
+// but number of columns is the same as in default select, rows are scanned by position
+// (as custom queries were scanned before). Plan is cached per model type and columns.
+
+// maxScanPlans bounds scan plans cache of Repo, cache is reset when it is full
+// (custom queries with arbitrary columns could grow it forever)
+const maxScanPlans = 256
+
+// WithStrictScan makes Fetch/FetchOne fail on result columns that do not match
+// any model field. By default such columns are ignored.
+func WithStrictScan() RepoOption {
+	return func(o *repoOptions) {
+		o.strictScan = true
+	}
+}
+
+// columnTarget is a scan destination of result column:
+// nested == 0 is model field, nested > 0 is field of nested join (field -1 is found flag),
+// nested < 0 is unknown column
+type columnTarget struct {
+	nested int
+	field  int
+}
+
+type scanPlan struct {
+	targets []columnTarget
+}
+
+// scanPlan returns cached plan of rows columns for obj with nested joins
+func (r *Repo) scanPlan(rs rows, obj Model, nested []nestedJoin) (*scanPlan, error) {
+	columns, err := rs.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	key := reflect.TypeOf(obj).String() + "|" + strings.Join(columns, ",")
+	for _, n := range nested {
+		key += "|" + n.alias + "=" + n.field
+	}
+
+	r.plansMu.Lock()
+	p, ok := r.plans[key]
+	r.plansMu.Unlock()
+	if ok {
+		return p, nil
+	}
+
+	p, err = r.buildScanPlan(columns, obj, nested)
+	if err != nil {
+		return nil, err
+	}
+
+	r.plansMu.Lock()
+	if r.plans == nil || len(r.plans) >= maxScanPlans {
+		r.plans = map[string]*scanPlan{}
+	}
+	r.plans[key] = p
+	r.plansMu.Unlock()
+
+	return p, nil
+}
+
+func (r *Repo) buildScanPlan(columns []string, obj Model, nested []nestedJoin) (*scanPlan, error) {
+	names, _, err := fieldsDest(r.b, obj, false)
+	if err != nil {
+		return nil, err
+	}
+
+	nestedNames := make([][]string, len(nested))
+	for i, n := range nested {
+		d, err := n.scanDest(r.b, obj)
+		if err != nil {
+			return nil, err
+		}
+		nestedNames[i] = d.names
+	}
+
+	p := &scanPlan{targets: make([]columnTarget, len(columns))}
+	used := map[columnTarget]bool{}
+
+	// exact names first, then aliased fields (db:"name=p.description" is selected as description)
+	for _, exact := range []bool{true, false} {
+		for i, col := range columns {
+			if exact {
+				p.targets[i] = columnTarget{nested: -1}
+			} else if p.targets[i].nested >= 0 {
+				continue
+			}
+
+			t, ok := matchColumn(col, names, nested, nestedNames, used, exact)
+			if ok {
+				p.targets[i] = t
+				used[t] = true
+			}
+		}
+	}
+
+	matched := 0
+	for i, t := range p.targets {
+		if t.nested >= 0 {
+			matched++
+		} else if r.strictScan {
+			return nil, fmt.Errorf("unknown column '%s' for %T", columns[i], obj)
+		}
+	}
+
+	if pos := r.positionalTargets(names, nested, nestedNames); matched == 0 && len(pos) == len(columns) {
+		p.targets = pos
+	}
+
+	return p, nil
+}
+
+// positionalTargets returns targets in order of default select: table fields
+// (without relation and nested join fields), then found flag and fields of each nested join
+func (r *Repo) positionalTargets(names []string, nested []nestedJoin, nestedNames [][]string) []columnTarget {
+	skip := r.relationFields()
+	for _, n := range nested {
+		skip = append(skip, n.field)
+	}
+
+	var ret []columnTarget
+	for i, name := range names {
+		if !hasString(skip, name) {
+			ret = append(ret, columnTarget{field: i})
+		}
+	}
+	for i := range nested {
+		ret = append(ret, columnTarget{nested: i + 1, field: -1})
+		for j := range nestedNames[i] {
+			ret = append(ret, columnTarget{nested: i + 1, field: j})
+		}
+	}
+
+	return ret
+}
+
+func matchColumn(col string, names []string, nested []nestedJoin, nestedNames [][]string, used map[columnTarget]bool, exact bool) (columnTarget, bool) {
+	match := func(name string) bool {
+		if exact {
+			return name == col
+		}
+		return name[strings.LastIndex(name, ".")+1:] == col
+	}
+
+	for i, n := range names {
+		t := columnTarget{field: i}
+		if !used[t] && match(n) {
+			return t, true
+		}
+	}
+
+	if !exact {
+		return columnTarget{}, false
+	}
+
+	for i, n := range nested {
+		prefix := n.alias + "__"
+		if !strings.HasPrefix(col, prefix) {
+			continue
+		}
+
+		name := col[len(prefix):]
+		if name == "found" {
+			return columnTarget{nested: i + 1, field: -1}, true
+		}
+		for j, nn := range nestedNames[i] {
+			if nn == name {
+				return columnTarget{nested: i + 1, field: j}, true
+			}
+		}
+	}
+
+	return columnTarget{}, false
+}
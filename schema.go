@@ -0,0 +1,373 @@
+package protosql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/fabregas/protosql/options"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Schema is a table definition derived from proto message
+type Schema struct {
+	Table      string
+	Columns    []Column
+	PrimaryKey []string
+	Indexes    []Index
+}
+
+type Column struct {
+	Name    string
+	Type    string
+	NotNull bool
+	// filled by Repo.Introspect only
+	HasDefault bool
+}
+
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	// Constraint is set for unique index backed by UNIQUE constraint (see WithUnique),
+	// otherwise it is created by CREATE UNIQUE INDEX
+	Constraint bool
+}
+
+type SchemaOption func(*Schema)
+
+// PrimaryKey overrides default primary key ("id" if model has such field)
+func PrimaryKey(columns ...string) SchemaOption {
+	return func(s *Schema) {
+		s.PrimaryKey = columns
+	}
+}
+
+func WithIndex(columns ...string) SchemaOption {
+	return func(s *Schema) {
+		s.Indexes = append(s.Indexes, Index{Name: indexName(s.Table, columns, "idx"), Columns: columns})
+	}
+}
+
+func WithUnique(columns ...string) SchemaOption {
+	return func(s *Schema) {
+		s.Indexes = append(s.Indexes, Index{Name: indexName(s.Table, columns, "key"), Columns: columns, Unique: true, Constraint: true})
+	}
+}
+
+// Nullable drops NOT NULL constraint from columns
+func Nullable(columns ...string) SchemaOption {
+	return func(s *Schema) {
+		for _, c := range columns {
+			if col := s.Column(c); col != nil {
+				col.NotNull = false
+			}
+		}
+	}
+}
+
+// WithoutColumns removes columns of fields which are not stored in table (e.g. relations)
+func WithoutColumns(columns ...string) SchemaOption {
+	return func(s *Schema) {
+		var ret []Column
+		for _, c := range s.Columns {
+			if !hasString(columns, c.Name) {
+				ret = append(ret, c)
+			}
+		}
+		s.Columns = ret
+	}
+}
+
+func indexName(table string, columns []string, suffix string) string {
+	return fmt.Sprintf("%s_%s_%s", table, strings.Join(columns, "_"), suffix)
+}
+
+// TableSchema builds table definition for model,
+// column types follows the values produced by toSqlParam.
+// google.protobuf.Duration fields are stored as bigint milliseconds with both backends
+// (pgx backend can read interval columns too, but schema and written values are bigint).
+// Options declared in proto (protosql.table, column of protosql.field) are applied before opts.
+func TableSchema(table string, obj Model, opts ...SchemaOption) *Schema {
+	s := &Schema{Table: table}
+	if m, ok := obj.(protoreflect.ProtoMessage); ok {
+		opts = append(protoOptions(m.ProtoReflect().Descriptor()), opts...)
+	}
+
+	for _, f := range parseProtoMsg(obj) {
+		s.Columns = append(s.Columns, Column{
+			Name:    f.name,
+			Type:    sqlType(f.val.Type()),
+			NotNull: !isOptionalField(f.tag) && !isNullableType(f.val.Type()),
+		})
+	}
+
+	return s.apply(opts)
+}
+
+// TableSchemaFromDescriptor builds table definition for message descriptor
+// (e.g. loaded from protoc descriptor set) using the same type mapping as TableSchema
+func TableSchemaFromDescriptor(table string, md protoreflect.MessageDescriptor, opts ...SchemaOption) *Schema {
+	s := &Schema{Table: table}
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+			// oneof fields are not mapped by parseProtoMsg
+			continue
+		}
+
+		s.Columns = append(s.Columns, Column{
+			Name:    string(fd.Name()),
+			Type:    descSqlType(fd),
+			NotNull: !fd.HasOptionalKeyword() && !fd.IsList() && fd.Kind() != protoreflect.BytesKind,
+		})
+	}
+
+	return s.apply(append(protoOptions(md), opts...))
+}
+
+// protoOptions returns schema options declared by protosql.table and protosql.field column proto options
+func protoOptions(md protoreflect.MessageDescriptor) []SchemaOption {
+	var ret []SchemaOption
+
+	if t, ok := proto.GetExtension(md.Options(), options.E_Table).(*options.Table); ok && t != nil {
+		if len(t.PrimaryKey) > 0 {
+			ret = append(ret, PrimaryKey(t.PrimaryKey...))
+		}
+		for _, idx := range t.Indexes {
+			if idx.Unique {
+				ret = append(ret, WithUnique(idx.Columns...))
+			} else {
+				ret = append(ret, WithIndex(idx.Columns...))
+			}
+		}
+	}
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		f, ok := proto.GetExtension(fd.Options(), options.E_Field).(*options.Field)
+		if !ok || f.GetColumn() == nil {
+			continue
+		}
+		c := f.Column
+
+		name := string(fd.Name())
+		if c.Index {
+			ret = append(ret, WithIndex(name))
+		}
+		if c.Unique {
+			ret = append(ret, WithUnique(name))
+		}
+		if c.Nullable {
+			ret = append(ret, Nullable(name))
+		}
+	}
+
+	return ret
+}
+
+func (s *Schema) apply(opts []SchemaOption) *Schema {
+	if s.Column("id") != nil {
+		s.PrimaryKey = []string{"id"}
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *Schema) Column(name string) *Column {
+	for i := range s.Columns {
+		if s.Columns[i].Name == name {
+			return &s.Columns[i]
+		}
+	}
+
+	return nil
+}
+
+// DDL returns CREATE TABLE statement followed by CREATE INDEX statements
+func (s *Schema) DDL() string {
+	var defs []string
+	for _, c := range s.Columns {
+		defs = append(defs, "\t"+c.definition())
+	}
+
+	if len(s.PrimaryKey) > 0 {
+		defs = append(defs, fmt.Sprintf("\tPRIMARY KEY (%s)", strings.Join(s.PrimaryKey, ", ")))
+	}
+
+	for _, idx := range s.Indexes {
+		if idx.Constraint {
+			defs = append(defs, fmt.Sprintf("\tCONSTRAINT %s UNIQUE (%s)", idx.Name, strings.Join(idx.Columns, ", ")))
+		}
+	}
+
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n);", s.Table, strings.Join(defs, ",\n")),
+	}
+
+	for _, idx := range s.Indexes {
+		if !idx.Constraint {
+			stmts = append(stmts, idx.createQuery(s.Table)+";")
+		}
+	}
+
+	return strings.Join(stmts, "\n") + "\n"
+}
+
+func (c Column) definition() string {
+	def := c.Name + " " + c.Type
+	if c.NotNull {
+		def += " NOT NULL"
+	}
+
+	return def
+}
+
+func (idx Index) createQuery(table string) string {
+	switch {
+	case idx.Constraint:
+		return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s UNIQUE (%s)", table, idx.Name, strings.Join(idx.Columns, ", "))
+	case idx.Unique:
+		return fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s)", idx.Name, table, strings.Join(idx.Columns, ", "))
+	}
+
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", idx.Name, table, strings.Join(idx.Columns, ", "))
+}
+
+var (
+	timeIfaceType     = reflect.TypeOf((*timeIface)(nil)).Elem()
+	durationIfaceType = reflect.TypeOf((*durationIface)(nil)).Elem()
+)
+
+func sqlType(t reflect.Type) string {
+	switch {
+	case t.Implements(timeIfaceType):
+		return "timestamptz"
+	case t.Implements(durationIfaceType):
+		// duration is stored in milliseconds (see TableSchema)
+		return "bigint"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return "integer"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "bigint"
+	case reflect.Float32:
+		return "real"
+	case reflect.Float64:
+		return "double precision"
+	case reflect.String:
+		return "text"
+	case reflect.Array, reflect.Slice:
+		if t.Elem().Kind() == reflect.Ptr {
+			return "jsonb"
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytea"
+		}
+		return sqlType(t.Elem()) + "[]"
+	default:
+		// maps and nested messages are stored as json
+		return "jsonb"
+	}
+}
+
+// ColumnKind is a way proto field value is stored in table column
+type ColumnKind int
+
+const (
+	ScalarColumn   ColumnKind = iota // numbers, strings, bool and bytes as is
+	EnumColumn                       // enum number as integer
+	ArrayColumn                      // repeated scalars and enums as array
+	JSONColumn                       // messages, maps, repeated messages and optional fields as json
+	TimeColumn                       // google.protobuf.Timestamp as timestamptz
+	DurationColumn                   // google.protobuf.Duration as bigint milliseconds (see TableSchema)
+)
+
+// FieldColumnKind returns storage of field, it is shared by schema and protoc-gen-protosql
+// (generated params and scan destinations), so both follow the same mapping
+func FieldColumnKind(fd protoreflect.FieldDescriptor) ColumnKind {
+	switch {
+	case fd.IsMap() || fd.HasOptionalKeyword():
+		return JSONColumn
+	case fd.IsList() && fd.Kind() == protoreflect.MessageKind:
+		return JSONColumn
+	case fd.IsList():
+		return ArrayColumn
+	case fd.Kind() == protoreflect.EnumKind:
+		return EnumColumn
+	case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+		switch fd.Message().FullName() {
+		case "google.protobuf.Timestamp":
+			return TimeColumn
+		case "google.protobuf.Duration":
+			return DurationColumn
+		}
+		return JSONColumn
+	}
+
+	return ScalarColumn
+}
+
+func descSqlType(fd protoreflect.FieldDescriptor) string {
+	switch FieldColumnKind(fd) {
+	case JSONColumn:
+		return "jsonb"
+	case TimeColumn:
+		return "timestamptz"
+	case DurationColumn:
+		return "bigint"
+	}
+
+	var t string
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		t = "boolean"
+	case protoreflect.EnumKind, protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		t = "integer"
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		t = "bigint"
+	case protoreflect.FloatKind:
+		t = "real"
+	case protoreflect.DoubleKind:
+		t = "double precision"
+	case protoreflect.StringKind:
+		t = "text"
+	case protoreflect.BytesKind:
+		t = "bytea"
+	}
+
+	if fd.IsList() {
+		t += "[]"
+	}
+
+	return t
+}
+
+func isNullableType(t reflect.Type) bool {
+	// nil slices are stored as NULL
+	return t.Kind() == reflect.Slice
+}
+
+func isOptionalField(tag reflect.StructTag) bool {
+	// proto3 optional fields are pointers with oneof tag
+	for _, p := range strings.Split(tag.Get("protobuf"), ",") {
+		if p == "oneof" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,338 @@
+package protosql
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Change is a single migration step
+type Change struct {
+	Up   string
+	Down string
+	// Destructive changes could lose data (dropped columns, type conversions)
+	Destructive bool
+}
+
+// Introspect reads current table definition from pg_catalog.
+// ErrNotFound is returned if table does not exist.
+func (r *Repo) Introspect(ctx context.Context) (*Schema, error) {
+	var exists bool
+	if err := r.queryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", []interface{}{r.table}, &exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	s := &Schema{Table: r.table}
+
+	rows, err := r.b.query(ctx, introspectColumnsQ, r.table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Column
+		if err := rows.Scan(&c.Name, &c.Type, &c.NotNull, &c.HasDefault); err != nil {
+			return nil, err
+		}
+		c.Type = normalizeType(c.Type)
+		s.Columns = append(s.Columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	idxRows, err := r.b.query(ctx, introspectIndexesQ, r.table)
+	if err != nil {
+		return nil, err
+	}
+	defer idxRows.Close()
+
+	for idxRows.Next() {
+		var (
+			idx     Index
+			primary bool
+		)
+		if err := idxRows.Scan(&idx.Name, &idx.Unique, &primary, &idx.Constraint, r.b.ArrayDest(&idx.Columns)); err != nil {
+			return nil, err
+		}
+		if primary {
+			s.PrimaryKey = idx.Columns
+			continue
+		}
+		s.Indexes = append(s.Indexes, idx)
+	}
+
+	return s, idxRows.Err()
+}
+
+const (
+	introspectColumnsQ = `SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull, a.atthasdef
+FROM pg_attribute a
+WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY a.attnum`
+
+	introspectIndexesQ = `SELECT i.relname, ix.indisunique, ix.indisprimary,
+	EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = ix.indexrelid AND c.contype = 'u'),
+	array_agg(a.attname::text ORDER BY k.n)
+FROM pg_index ix
+JOIN pg_class i ON i.oid = ix.indexrelid
+CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, n)
+JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = k.attnum
+WHERE ix.indrelid = $1::regclass
+GROUP BY i.relname, ix.indisunique, ix.indisprimary
+ORDER BY i.relname`
+)
+
+func (r *Repo) queryRow(ctx context.Context, q string, args []interface{}, dest ...interface{}) error {
+	r.logger.Debugf("QUERY: %s, ARGS: %+v", q, args)
+
+	rows, err := r.b.query(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+
+	return rows.Err()
+}
+
+var typeAliases = map[string]string{
+	"timestamp with time zone": "timestamptz",
+	"int":                      "integer",
+	"int4":                     "integer",
+	"int8":                     "bigint",
+	"bool":                     "boolean",
+	"float4":                   "real",
+	"float8":                   "double precision",
+}
+
+func normalizeType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	suffix := ""
+	for strings.HasSuffix(t, "[]") {
+		t = strings.TrimSuffix(t, "[]")
+		suffix += "[]"
+	}
+
+	if a, ok := typeAliases[t]; ok {
+		t = a
+	}
+
+	return t + suffix
+}
+
+// SchemaDiff compares live table with target schema and returns ordered migration steps
+func (r *Repo) SchemaDiff(ctx context.Context, target *Schema) ([]Change, error) {
+	current, err := r.Introspect(ctx)
+	if err == ErrNotFound {
+		return []Change{{Up: strings.TrimSpace(target.DDL()), Down: fmt.Sprintf("DROP TABLE %s;", target.Table), Destructive: false}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffSchema(current, target), nil
+}
+
+// DiffSchema returns steps migrating current schema to target one.
+// Order: drop indexes, add columns, alter columns, drop columns, primary key, create indexes.
+func DiffSchema(current, target *Schema) []Change {
+	var (
+		changes []Change
+		table   = target.Table
+	)
+
+	alter := func(up, down string, destructive bool) {
+		changes = append(changes, Change{
+			Up:          fmt.Sprintf("ALTER TABLE %s %s;", table, up),
+			Down:        fmt.Sprintf("ALTER TABLE %s %s;", table, down),
+			Destructive: destructive,
+		})
+	}
+
+	for _, idx := range current.Indexes {
+		if !hasIndex(target.Indexes, idx) {
+			changes = append(changes, Change{Up: idx.dropQuery(table) + ";", Down: idx.createQuery(table) + ";"})
+		}
+	}
+
+	for _, c := range target.Columns {
+		if current.Column(c.Name) != nil {
+			continue
+		}
+
+		def := c.definition()
+		if c.NotNull {
+			def += " DEFAULT " + zeroValue(c.Type)
+		}
+		alter("ADD COLUMN "+def, "DROP COLUMN "+c.Name, false)
+	}
+
+	for _, c := range target.Columns {
+		cur := current.Column(c.Name)
+		if cur == nil {
+			continue
+		}
+
+		if normalizeType(cur.Type) != normalizeType(c.Type) {
+			alter(
+				fmt.Sprintf("ALTER COLUMN %s TYPE %s USING %s::%s", c.Name, c.Type, c.Name, c.Type),
+				fmt.Sprintf("ALTER COLUMN %s TYPE %s USING %s::%s", c.Name, cur.Type, c.Name, cur.Type),
+				true,
+			)
+		}
+
+		switch {
+		case c.NotNull && !cur.NotNull:
+			alter("ALTER COLUMN "+c.Name+" SET NOT NULL", "ALTER COLUMN "+c.Name+" DROP NOT NULL", false)
+		case !c.NotNull && cur.NotNull:
+			alter("ALTER COLUMN "+c.Name+" DROP NOT NULL", "ALTER COLUMN "+c.Name+" SET NOT NULL", false)
+		}
+	}
+
+	for _, c := range current.Columns {
+		if target.Column(c.Name) == nil {
+			alter("DROP COLUMN "+c.Name, fmt.Sprintf("ADD COLUMN %s %s", c.Name, c.Type), true)
+		}
+	}
+
+	if !equalColumns(current.PrimaryKey, target.PrimaryKey) {
+		pk := table + "_pkey"
+		up, down := "DROP CONSTRAINT IF EXISTS "+pk, "DROP CONSTRAINT IF EXISTS "+pk
+		if len(target.PrimaryKey) > 0 {
+			up += fmt.Sprintf(", ADD CONSTRAINT %s PRIMARY KEY (%s)", pk, strings.Join(target.PrimaryKey, ", "))
+		}
+		if len(current.PrimaryKey) > 0 {
+			down += fmt.Sprintf(", ADD CONSTRAINT %s PRIMARY KEY (%s)", pk, strings.Join(current.PrimaryKey, ", "))
+		}
+		alter(up, down, false)
+	}
+
+	for _, idx := range target.Indexes {
+		if !hasIndex(current.Indexes, idx) {
+			changes = append(changes, Change{Up: idx.createQuery(table) + ";", Down: idx.dropQuery(table) + ";"})
+		}
+	}
+
+	return changes
+}
+
+func (idx Index) dropQuery(table string) string {
+	if idx.Constraint {
+		return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s", table, idx.Name)
+	}
+
+	return fmt.Sprintf("DROP INDEX IF EXISTS %s", idx.Name)
+}
+
+func hasIndex(lst []Index, idx Index) bool {
+	for _, i := range lst {
+		if i.Name == idx.Name && i.Unique == idx.Unique && equalColumns(i.Columns, idx.Columns) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func equalColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// zeroValue returns proto default value of column type,
+// it is used for adding NOT NULL columns into non empty tables
+func zeroValue(sqlType string) string {
+	if strings.HasSuffix(sqlType, "[]") {
+		return "'{}'"
+	}
+
+	switch sqlType {
+	case "boolean":
+		return "false"
+	case "text":
+		return "''"
+	case "timestamptz":
+		return "'epoch'"
+	case "jsonb":
+		return "'null'"
+	case "bytea":
+		return "''::bytea"
+	default:
+		return "0"
+	}
+}
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// WriteMigration writes changes into next versioned pair of files
+// <version>_<name>.up.sql and <version>_<name>.down.sql in dir.
+// Destructive steps are marked with comment.
+func WriteMigration(dir, name string, changes []Change) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	version := 0
+	for _, e := range entries {
+		m := migrationFileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		if v, _ := strconv.Atoi(m[1]); v > version {
+			version = v
+		}
+	}
+
+	base := fmt.Sprintf("%04d_%s", version+1, name)
+
+	var up, down []string
+	for _, c := range changes {
+		stmt := c.Up
+		if c.Destructive {
+			stmt = "-- DESTRUCTIVE: review before applying\n" + stmt
+		}
+		up = append(up, stmt)
+	}
+	for i := len(changes) - 1; i >= 0; i-- {
+		down = append(down, changes[i].Down)
+	}
+
+	upFile := filepath.Join(dir, base+".up.sql")
+	if err := os.WriteFile(upFile, []byte(strings.Join(up, "\n")+"\n"), 0644); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, base+".down.sql"), []byte(strings.Join(down, "\n")+"\n"), 0644); err != nil {
+		return "", err
+	}
+
+	return upFile, nil
+}
@@ -0,0 +1,174 @@
+package protosql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fabregas/protosql/internal/testpb"
+)
+
+func TestTableSchema(t *testing.T) {
+	s := TableSchema("xxx_table", &TestModel{}, WithIndex("name"), WithUnique("website"), Nullable("description"))
+
+	expected := `CREATE TABLE IF NOT EXISTS xxx_table (
+	id integer NOT NULL,
+	name text NOT NULL,
+	website text NOT NULL,
+	description text,
+	status integer NOT NULL,
+	create_time timestamptz NOT NULL,
+	update_time timestamptz NOT NULL,
+	online_duration bigint NOT NULL,
+	count bigint NOT NULL,
+	nested jsonb NOT NULL,
+	tags text[],
+	nested_list jsonb,
+	blob bytea,
+	old_statuses integer[],
+	PRIMARY KEY (id),
+	CONSTRAINT xxx_table_website_key UNIQUE (website)
+);
+CREATE INDEX IF NOT EXISTS xxx_table_name_idx ON xxx_table (name);
+`
+	expectEq(t, s.DDL(), expected)
+}
+
+func TestTableSchemaOptions(t *testing.T) {
+	expected := `CREATE TABLE IF NOT EXISTS accounts (
+	id bigint NOT NULL,
+	tenant text NOT NULL,
+	name text NOT NULL,
+	email text NOT NULL,
+	login text NOT NULL,
+	note text,
+	ttl bigint NOT NULL,
+	PRIMARY KEY (tenant, id),
+	CONSTRAINT accounts_tenant_email_key UNIQUE (tenant, email),
+	CONSTRAINT accounts_login_key UNIQUE (login),
+	CONSTRAINT accounts_name_key UNIQUE (name)
+);
+CREATE INDEX IF NOT EXISTS accounts_tenant_name_idx ON accounts (tenant, name);
+CREATE INDEX IF NOT EXISTS accounts_name_idx ON accounts (name);
+`
+
+	s := TableSchema("accounts", &testpb.Account{}, WithUnique("name"))
+	expectEq(t, s.DDL(), expected)
+
+	s = TableSchemaFromDescriptor("accounts", (&testpb.Account{}).ProtoReflect().Descriptor(), WithUnique("name"))
+	expectEq(t, s.DDL(), expected)
+}
+
+func TestDiffSchema(t *testing.T) {
+	current := &Schema{
+		Table: "xxx_table",
+		Columns: []Column{
+			{Name: "id", Type: "integer", NotNull: true},
+			{Name: "name", Type: "character varying(64)", NotNull: true},
+			{Name: "legacy", Type: "text"},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes: []Index{
+			{Name: "xxx_table_legacy_idx", Columns: []string{"legacy"}},
+			{Name: "xxx_table_website_key", Columns: []string{"website"}, Unique: true},
+			{Name: "xxx_table_name_key", Columns: []string{"name"}, Unique: true, Constraint: true},
+		},
+	}
+
+	target := &Schema{
+		Table: "xxx_table",
+		Columns: []Column{
+			{Name: "id", Type: "integer", NotNull: true},
+			{Name: "name", Type: "text", NotNull: true},
+			{Name: "count", Type: "bigint", NotNull: true},
+		},
+		PrimaryKey: []string{"id"},
+	}
+	WithIndex("name")(target)
+
+	changes := DiffSchema(current, target)
+
+	var up []string
+	for _, c := range changes {
+		up = append(up, c.Up)
+	}
+	expectEq(t, up, []string{
+		"DROP INDEX IF EXISTS xxx_table_legacy_idx;",
+		"DROP INDEX IF EXISTS xxx_table_website_key;",
+		"ALTER TABLE xxx_table DROP CONSTRAINT IF EXISTS xxx_table_name_key;",
+		"ALTER TABLE xxx_table ADD COLUMN count bigint NOT NULL DEFAULT 0;",
+		"ALTER TABLE xxx_table ALTER COLUMN name TYPE text USING name::text;",
+		"ALTER TABLE xxx_table DROP COLUMN legacy;",
+		"CREATE INDEX IF NOT EXISTS xxx_table_name_idx ON xxx_table (name);",
+	})
+	expectEq(t, changes[1].Down, "CREATE UNIQUE INDEX IF NOT EXISTS xxx_table_website_key ON xxx_table (website);")
+	expectEq(t, changes[2].Down, "ALTER TABLE xxx_table ADD CONSTRAINT xxx_table_name_key UNIQUE (name);")
+	expectEq(t, changes[4].Destructive, true)
+	expectEq(t, changes[5].Destructive, true)
+	expectEq(t, changes[5].Down, "ALTER TABLE xxx_table ADD COLUMN legacy text;")
+}
+
+func TestValidateSchema(t *testing.T) {
+	current := &Schema{
+		Table: "xxx_table",
+		Columns: []Column{
+			{Name: "id", Type: "bigint", NotNull: true},
+			{Name: "name", Type: "character varying(64)", NotNull: true},
+			{Name: "tags", Type: "text[]"},
+			{Name: "count", Type: "integer", NotNull: true},
+			{Name: "legacy", Type: "text", NotNull: true},
+			{Name: "created_by", Type: "text", NotNull: true, HasDefault: true},
+			{Name: "online_duration", Type: "interval", NotNull: true},
+			{Name: "idle_duration", Type: "interval", NotNull: true},
+		},
+	}
+
+	target := &Schema{
+		Table: "xxx_table",
+		Columns: []Column{
+			{Name: "id", Type: "integer", NotNull: true},
+			{Name: "name", Type: "text", NotNull: true},
+			{Name: "tags", Type: "text[]"},
+			{Name: "count", Type: "bigint", NotNull: true},
+			{Name: "nested", Type: "jsonb", NotNull: true},
+			{Name: "online_duration", Type: "bigint", NotNull: true},
+			{Name: "idle_duration", Type: "bigint", NotNull: true},
+		},
+	}
+
+	err := validateSchema(current, target, []string{"online_duration"})
+	serr, ok := err.(*SchemaError)
+	if !ok {
+		t.Fatalf("validateSchema() should return *SchemaError, got %v", err)
+	}
+	expectEq(t, serr.Problems, []string{
+		"column count has type integer, model expects bigint",
+		"missing column nested jsonb",
+		"column idle_duration has type interval, model expects bigint",
+		"extra column legacy is NOT NULL without default",
+	})
+}
+
+func TestSchemaValidationWithRelations(t *testing.T) {
+	withDBMock(t, func(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) {
+		rows := sqlmock.NewRows([]string{"attname", "format_type", "attnotnull", "atthasdef"})
+		for _, c := range TableSchema("xxx_table", &TestModel{}, WithoutColumns("nested", "nested_list")).Columns {
+			rows.AddRow(c.Name, c.Type, c.NotNull, false)
+		}
+		mock.ExpectQuery(`^SELECT to_regclass`).WithArgs("xxx_table").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`^SELECT a.attname`).WithArgs("xxx_table").WillReturnRows(rows)
+		mock.ExpectQuery(`^SELECT`).WithArgs("xxx_table").WillReturnRows(sqlmock.NewRows([]string{"name", "unique", "primary", "constraint", "columns"}))
+
+		partners := NewRepo(db, "partners", &NestedModel{}, dummyLogger{})
+		r := NewRepo(db, "xxx_table", &TestModel{}, dummyLogger{},
+			WithBelongsTo("nested", partners, "count"),
+			WithHasMany("nested_list", partners, "model_id"),
+			WithSchemaValidation(),
+		)
+		expectEq(t, r.relationFields(), []string{"nested", "nested_list"})
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -19,6 +19,9 @@ import (
 type Sorting struct {
 	FieldName string
 	Order     string
+
+	rank    *matchVal   // ts_rank relevance sorting (see RankBy)
+	similar *similarVal // trigram similarity sorting (see SimilarityBy)
 }
 
 func Asc(field string) *Sorting {
@@ -66,10 +69,18 @@ func newSorting(s interface{}) *Sorting {
 	return nil
 }
 
-func sortQuery(s *Sorting) string {
+// idx is a first placeholder index for sorting args
+func sortQuery(s *Sorting, idx int, o queryOpts) (string, []interface{}, error) {
 	if s == nil {
-		return ""
+		return "", nil, nil
+	}
+
+	switch {
+	case s.rank != nil:
+		return rankQuery(s, idx, o.searchConfig)
+	case s.similar != nil:
+		return similarityQuery(s, idx, o)
 	}
 
-	return fmt.Sprintf(" ORDER BY %s %s", s.FieldName, s.Order)
+	return fmt.Sprintf(" ORDER BY %s %s", s.FieldName, s.Order), nil, nil
 }
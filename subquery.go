@@ -0,0 +1,103 @@
+package protosql
+
+import (
+	"errors"
+	"fmt"
+)
+
+// subquery filters: query of (another) Repo is embedded into condition,
+// its arguments are numbered after preceding filter arguments.
+
+// InQuery adds condition lval IN (subquery). Subquery should select single column (see Repo.SelectFields).
+func (f *Filter) InQuery(lval string, q *repoQ) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: inQueryOp, rval: subQueryVal(q)})
+	return f
+}
+
+// Exists adds condition EXISTS (subquery). Subquery can refer outer query columns by table name or alias.
+func (f *Filter) Exists(q *repoQ) *Filter {
+	f.addExpr(filterExpr{op: existsOp, rval: subQueryVal(q)})
+	return f
+}
+
+// NotExists adds condition NOT EXISTS (subquery)
+func (f *Filter) NotExists(q *repoQ) *Filter {
+	f.addExpr(filterExpr{op: notExistsOp, rval: subQueryVal(q)})
+	return f
+}
+
+// nil query is ignored as nil value
+func subQueryVal(q *repoQ) interface{} {
+	if q == nil {
+		return nil
+	}
+	return q
+}
+
+var errSubQuery = errors.New("union and global search queries can't be used as subquery")
+
+func (f filterExpr) formatSubQuery(gidx int) (string, []interface{}, error) {
+	q := f.rval.(*repoQ)
+	if len(q.unionQueries) > 0 || q.globalSearchTerm != "" {
+		return "", nil, errSubQuery
+	}
+
+	sq, args, err := q.buildQ(gidx, "", q.pager)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch f.op {
+	case inQueryOp:
+		return fmt.Sprintf("%s IN (%s)", f.lval, sq), args, nil
+	case existsOp:
+		return fmt.Sprintf("EXISTS (%s)", sq), args, nil
+	}
+
+	return fmt.Sprintf("NOT EXISTS (%s)", sq), args, nil
+}
+
+// hasSubQuery reports whether filter (or its groups) contains subquery expressions
+func (f *Filter) hasSubQuery() bool {
+	if f == nil {
+		return false
+	}
+
+	for _, e := range f.exprList {
+		switch {
+		case e.op == inQueryOp || e.op == existsOp || e.op == notExistsOp:
+			return true
+		case e.op.isGroup() && e.rval.(*Filter).hasSubQuery():
+			return true
+		}
+	}
+
+	return false
+}
+
+// stubSubQueries returns filter copy with subqueries rendered as placeholders,
+// building of subquery may access database (pg_trgm check)
+func (f *Filter) stubSubQueries() *Filter {
+	if !f.hasSubQuery() {
+		return f
+	}
+
+	ret := &Filter{exprList: make([]filterExpr, len(f.exprList)), strict: f.strict, allowAll: f.allowAll}
+	for i, e := range f.exprList {
+		switch {
+		case e.op.isGroup():
+			e.rval = e.rval.(*Filter).stubSubQueries()
+		case e.rval == nil:
+			// ignored expression
+		case e.op == inQueryOp:
+			e = filterExpr{lval: e.lval + " IN (...)", op: rawOp}
+		case e.op == existsOp:
+			e = filterExpr{lval: "EXISTS (...)", op: rawOp}
+		case e.op == notExistsOp:
+			e = filterExpr{lval: "NOT EXISTS (...)", op: rawOp}
+		}
+		ret.exprList[i] = e
+	}
+
+	return ret
+}
@@ -0,0 +1,193 @@
+package protosql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// time series aggregation: rows are grouped into time buckets (date_trunc or date_bin)
+// and joined with generate_series, so buckets without rows are present
+
+// TimeBucket is aggregated values of time interval
+type TimeBucket struct {
+	Start  time.Time
+	Values map[string]float64 // aggregate alias -> value, 0 for empty bucket
+}
+
+type seriesQ struct {
+	q        *repoQ
+	column   string
+	interval string
+	aggs     []Aggregation
+
+	from, to time.Time
+	tz       string
+}
+
+// TimeSeries aggregates query rows by time buckets of column.
+// interval is date_trunc unit (minute, hour, day, week, month, year ...)
+// or fixed interval like "15 minutes" (date_bin, PostgreSQL 14+).
+// Without Between buckets from first to last non empty bucket are returned.
+func (q *repoQ) TimeSeries(column, interval string, aggs ...Aggregation) *seriesQ {
+	return &seriesQ{q: q, column: column, interval: interval, aggs: aggs}
+}
+
+// Between limits rows and buckets by time range (including buckets of from and to)
+func (s *seriesQ) Between(from, to time.Time) *seriesQ {
+	s.from, s.to = from, to
+	return s
+}
+
+// Timezone sets time zone of buckets boundaries (e.g. Europe/Kyiv), session time zone is used by default
+func (s *seriesQ) Timezone(tz string) *seriesQ {
+	s.tz = tz
+	return s
+}
+
+var (
+	truncUnits = map[string]bool{
+		"microseconds": true, "milliseconds": true, "second": true, "minute": true, "hour": true,
+		"day": true, "week": true, "month": true, "quarter": true, "year": true,
+	}
+	seriesIntervalRe = regexp.MustCompile(`^(\d+)\s*(microsecond|millisecond|second|minute|hour|day|week|month|quarter|year)s?$`)
+	timezoneRe       = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_/+\-]*$`)
+)
+
+// bucket returns bucket expression of local time expression and step of buckets
+func (s *seriesQ) bucket(local string) (string, string, error) {
+	interval := strings.ToLower(strings.TrimSpace(s.interval))
+	if truncUnits[interval] {
+		return fmt.Sprintf("date_trunc('%s', %s)", interval, local), "1 " + interval, nil
+	}
+
+	m := seriesIntervalRe.FindStringSubmatch(interval)
+	if m == nil {
+		return "", "", fmt.Errorf("invalid time series interval %q", s.interval)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n == 0 {
+		return "", "", fmt.Errorf("invalid time series interval %q", s.interval)
+	}
+
+	step := fmt.Sprintf("%d %s", n, m[2])
+	if n == 1 && truncUnits[m[2]] {
+		return fmt.Sprintf("date_trunc('%s', %s)", m[2], local), step, nil
+	}
+	switch m[2] {
+	case "month", "quarter", "year":
+		return "", "", fmt.Errorf("time series interval %q is not fixed, use single %s", s.interval, m[2])
+	}
+
+	origin := "TIMESTAMPTZ '2000-01-03'"
+	if s.tz != "" {
+		origin = "TIMESTAMP '2000-01-03'"
+	}
+	return fmt.Sprintf("date_bin('%s', %s, %s)", step, local, origin), step, nil
+}
+
+func (s *seriesQ) build() (string, []interface{}, error) {
+	q := *s.q
+	if len(q.unionQueries) > 0 || q.globalSearchTerm != "" || q.query != "" {
+		return "", nil, errAggregateQuery
+	}
+	if len(q.groupBy) > 0 {
+		return "", nil, fmt.Errorf("time series of grouped query is not supported")
+	}
+
+	// buckets are computed in local time of time zone,
+	// AT TIME ZONE converts timestamptz into local timestamp and back
+	atZone := func(e string) string { return e }
+	if s.tz != "" {
+		if !timezoneRe.MatchString(s.tz) {
+			return "", nil, fmt.Errorf("invalid time zone %q", s.tz)
+		}
+		atZone = func(e string) string { return fmt.Sprintf("(%s AT TIME ZONE '%s')", e, s.tz) }
+	}
+
+	bucket, step, err := s.bucket(atZone(s.column))
+	if err != nil {
+		return "", nil, err
+	}
+
+	ranged := !s.from.IsZero() && !s.to.IsZero()
+	if ranged {
+		f := NewFilter().Gte(s.column, s.from).Lte(s.column, s.to)
+		if q.filter != nil {
+			f.And(q.filter)
+		}
+		q.filter = f
+	}
+
+	q.selectExprs = []string{bucket + " AS bucket"}
+	var values []string
+	for _, agg := range s.aggs {
+		q.selectExprs = append(q.selectExprs, agg.String())
+		values = append(values, fmt.Sprintf("COALESCE(data.%s, 0)", agg.alias))
+	}
+	q.groupBy = []string{"1"}
+	q.sorting, q.pager, q.lock = nil, nil, false
+
+	dataQ, args, err := q.buildQ(1, "", nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	from, to := "(SELECT min(bucket) FROM data)", "(SELECT max(bucket) FROM data)"
+	if ranged {
+		from, _, _ = s.bucket(atZone(fmt.Sprintf("$%d::timestamptz", len(args)+1)))
+		to, _, _ = s.bucket(atZone(fmt.Sprintf("$%d::timestamptz", len(args)+2)))
+		args = append(args, s.from, s.to)
+	}
+
+	return fmt.Sprintf(
+		"WITH data AS (%s), series AS (SELECT generate_series(%s, %s, interval '%s') AS bucket) "+
+			"SELECT %s AS bucket, %s FROM series LEFT JOIN data ON data.bucket = series.bucket ORDER BY series.bucket",
+		dataQ, from, to, step, atZone("series.bucket"), strings.Join(values, ", "),
+	), args, nil
+}
+
+// Fetch returns buckets ordered by time
+func (s *seriesQ) Fetch() ([]TimeBucket, error) {
+	if len(s.aggs) == 0 {
+		return nil, fmt.Errorf("time series without aggregates")
+	}
+
+	req, args, err := s.build()
+	if err != nil {
+		return nil, err
+	}
+
+	defer addMetricSince("select", req, time.Now())
+
+	s.q.r.logger.Debugf("QUERY: %s, ARGS: %+v", req, args)
+
+	rows, err := s.q.r.b.query(s.q.ctx, req, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []TimeBucket
+	for rows.Next() {
+		b := TimeBucket{Values: make(map[string]float64, len(s.aggs))}
+		vals := make([]float64, len(s.aggs))
+
+		dest := []interface{}{&b.Start}
+		for i := range vals {
+			dest = append(dest, &vals[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		for i, agg := range s.aggs {
+			b.Values[agg.alias] = vals[i]
+		}
+		ret = append(ret, b)
+	}
+
+	return ret, rows.Err()
+}
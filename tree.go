@@ -0,0 +1,152 @@
+package protosql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// hierarchical queries over tables with parent id column (WITH RECURSIVE)
+
+// TreeNode is a row of hierarchical query. Depth is 0 for start rows,
+// Path contains ids from start row to the node.
+type TreeNode struct {
+	Model Model
+	Depth int
+	Path  []string
+}
+
+type treeQ struct {
+	r        *Repo
+	ctx      context.Context
+	parent   string  // parent id column
+	start    *Filter // start rows condition
+	up       bool    // walk from child to parent
+	maxDepth int
+	filter   *Filter
+}
+
+// Tree selects all rows of hierarchy starting from roots: parentColumn is NULL or
+// zero value (non-optional proto3 fields are stored NOT NULL). Use Roots to select other roots.
+func (r *Repo) Tree(ctx context.Context, parentColumn string) *treeQ {
+	start := NewFilter().IsNull(parentColumn)
+	for _, f := range parseProtoMsg(r.model) {
+		if f.name == parentColumn && f.val.Kind() != reflect.Ptr {
+			start = NewFilter().Or(start.Eq(parentColumn, reflect.Zero(f.val.Type()).Interface()))
+		}
+	}
+
+	return &treeQ{r: r, ctx: ctx, parent: parentColumn, start: start}
+}
+
+// Roots replaces condition of start rows
+func (t *treeQ) Roots(f *Filter) *treeQ {
+	t.start = f
+	return t
+}
+
+// Descendants selects row with id and all its descendants
+func (r *Repo) Descendants(ctx context.Context, parentColumn string, id interface{}) *treeQ {
+	return &treeQ{r: r, ctx: ctx, parent: parentColumn, start: NewFilter().Eq("id", id)}
+}
+
+// Ancestors selects row with id and all its ancestors up to root
+func (r *Repo) Ancestors(ctx context.Context, parentColumn string, id interface{}) *treeQ {
+	return &treeQ{r: r, ctx: ctx, parent: parentColumn, start: NewFilter().Eq("id", id), up: true}
+}
+
+// MaxDepth limits depth of selected rows (0 means no limit)
+func (t *treeQ) MaxDepth(depth int) *treeQ {
+	t.maxDepth = depth
+	return t
+}
+
+// Where filters selected rows, tree_depth and tree_path columns can be used in conditions
+func (t *treeQ) Where(f *Filter) *treeQ {
+	t.filter = f
+	return t
+}
+
+// build returns recursive expression of tree rows with tree_depth and tree_path columns,
+// rows already found in path are not visited again (cycles)
+func (t *treeQ) build(idx int) (string, []interface{}, error) {
+	o, err := t.r.queryOpts(t.ctx, t.start.usesTrgm())
+	if err != nil {
+		return "", nil, err
+	}
+
+	sq, args, err := t.start.prepare(o).toQuery(idx, "AND")
+	if err != nil {
+		return "", nil, err
+	}
+	if sq == "" {
+		return "", nil, fmt.Errorf("tree start condition is empty")
+	}
+
+	join := fmt.Sprintf("t.%s = tree.id", t.parent)
+	if t.up {
+		join = fmt.Sprintf("t.id = tree.%s", t.parent)
+	}
+
+	step := "NOT t.id::text = ANY(tree.tree_path)"
+	if t.maxDepth > 0 {
+		step += fmt.Sprintf(" AND tree.tree_depth < %d", t.maxDepth)
+	}
+
+	return fmt.Sprintf(
+		"SELECT t.*, 0 AS tree_depth, ARRAY[t.id::text] AS tree_path FROM %s AS t WHERE %s "+
+			"UNION ALL "+
+			"SELECT t.*, tree.tree_depth + 1, tree.tree_path || t.id::text FROM %s AS t JOIN tree ON %s WHERE %s",
+		t.r.table, sq, t.r.table, join, step,
+	), filterArgs(t.r.b, args), nil
+}
+
+// query selects model columns, tree_depth and tree_path ordered by path (by depth for ancestors)
+func (t *treeQ) query() *repoQ {
+	var fields []string
+	for _, f := range t.r.fields {
+		fields = append(fields, "tree."+f)
+	}
+	fields = append(fields, "tree.tree_depth", "tree.tree_path")
+
+	order := Asc("tree_path")
+	if t.up {
+		order = Asc("tree_depth")
+	}
+
+	q := t.r.SelectCustom(t.ctx, fmt.Sprintf("SELECT %s FROM tree ", strings.Join(fields, ",")))
+	q.ctes = []cte{{name: "tree", recursive: true, build: t.build}}
+
+	return q.Where(t.filter).OrderBy(order)
+}
+
+func (t *treeQ) Fetch() ([]TreeNode, error) {
+	rows, err := t.query().exec()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mt := reflect.TypeOf(t.r.model)
+	if mt.Kind() == reflect.Ptr {
+		mt = mt.Elem()
+	}
+
+	var ret []TreeNode
+	for rows.Next() {
+		obj := reflect.New(mt).Interface().(Model)
+		dest, err := scanDest(t.r.b, obj, nil, t.r.relationFields(), false)
+		if err != nil {
+			return nil, err
+		}
+
+		n := TreeNode{Model: obj}
+		if err := rows.Scan(append(dest, &n.Depth, t.r.b.ArrayDest(&n.Path))...); err != nil {
+			return nil, err
+		}
+		ret = append(ret, n)
+	}
+
+	return ret, rows.Err()
+}
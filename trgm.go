@@ -0,0 +1,135 @@
+package protosql
+
+import (
+	"fmt"
+)
+
+// trigram similarity search (pg_trgm extension).
+// If extension is not installed in database, ILIKE is used instead.
+
+type similarVal struct {
+	term      interface{}
+	threshold float64
+	word      bool
+}
+
+// Similar adds trigram similarity condition.
+// If threshold is 0, lval % term is used (pg_trgm.similarity_threshold is applied and trigram index can be used),
+// otherwise similarity(lval, term) >= threshold. Empty term is ignored.
+func (f *Filter) Similar(lval string, term interface{}, threshold float64) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: similarOp, rval: similarVal{term: term, threshold: threshold}})
+	return f
+}
+
+// WordSimilar is the same as Similar, but term is compared with words of lval (term <% lval or word_similarity)
+func (f *Filter) WordSimilar(lval string, term interface{}, threshold float64) *Filter {
+	f.addExpr(filterExpr{lval: lval, op: similarOp, rval: similarVal{term: term, threshold: threshold, word: true}})
+	return f
+}
+
+// termArg returns term argument, ignoreFilterErr means no search
+func (s similarVal) termArg() (string, error) {
+	arg, err := filterExpr{}.arg(s.term)
+	if err != nil {
+		return "", err
+	}
+
+	str, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("similarity search term must be a string, got %T", arg)
+	}
+	if str == "" {
+		return "", ignoreFilterErr
+	}
+
+	return str, nil
+}
+
+func (f filterExpr) formatSimilar(gidx int) (string, []interface{}, error) {
+	s := f.rval.(similarVal)
+
+	arg, err := s.termArg()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var stmt string
+	switch {
+	case s.word && s.threshold > 0:
+		stmt = fmt.Sprintf("word_similarity($%d, %s) >= %g", gidx, f.lval, s.threshold)
+	case s.word:
+		stmt = fmt.Sprintf("$%d <%% %s", gidx, f.lval)
+	case s.threshold > 0:
+		stmt = fmt.Sprintf("similarity(%s, $%d) >= %g", f.lval, gidx, s.threshold)
+	default:
+		stmt = fmt.Sprintf("%s %% $%d", f.lval, gidx)
+	}
+
+	return stmt, []interface{}{arg}, nil
+}
+
+func (f *Filter) usesTrgm() bool {
+	if f == nil {
+		return false
+	}
+
+	for _, e := range f.exprList {
+		switch {
+		case e.op == similarOp:
+			return true
+		case e.op.isGroup() && e.rval.(*Filter).usesTrgm():
+			return true
+		}
+	}
+
+	return false
+}
+
+// SimilarityBy returns sorting by trigram distance to term (most similar first)
+func SimilarityBy(lval string, term interface{}) *Sorting {
+	return &Sorting{FieldName: lval, Order: "ASC", similar: &similarVal{term: term}}
+}
+
+// WordSimilarityBy returns sorting by trigram word distance to term (most similar first)
+func WordSimilarityBy(lval string, term interface{}) *Sorting {
+	return &Sorting{FieldName: lval, Order: "ASC", similar: &similarVal{term: term, word: true}}
+}
+
+// similarityQuery falls back to prefix matches first ordering if pg_trgm is not installed
+func similarityQuery(s *Sorting, idx int, o queryOpts) (string, []interface{}, error) {
+	term, err := s.similar.termArg()
+	if err == ignoreFilterErr {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch {
+	case !o.trgm:
+		return fmt.Sprintf(" ORDER BY %s ILIKE $%d DESC", s.FieldName, idx), []interface{}{escapeLike(term) + "%"}, nil
+	case s.similar.word:
+		return fmt.Sprintf(" ORDER BY $%d <<-> %s", idx, s.FieldName), []interface{}{term}, nil
+	default:
+		return fmt.Sprintf(" ORDER BY %s <-> $%d", s.FieldName, idx), []interface{}{term}, nil
+	}
+}
+
+// searchRuleQuery returns condition of global search rule,
+// $1 is '%term%' and $2 is term (see repoQ.globalSearchExec)
+func searchRuleQuery(rule SearchRule, o queryOpts) string {
+	if rule.SimilarTo == "" {
+		return rule.Query
+	}
+
+	cond := fmt.Sprintf("%s %% $2", rule.SimilarTo)
+	if !o.trgm {
+		cond = fmt.Sprintf("%s ILIKE $1", rule.SimilarTo)
+	}
+
+	if rule.Query == "" {
+		return cond
+	}
+
+	return fmt.Sprintf("(%s OR %s)", rule.Query, cond)
+}
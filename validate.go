@@ -0,0 +1,116 @@
+package protosql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SchemaError lists differences between table and model found by Repo.Validate
+type SchemaError struct {
+	Table    string
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("table %s does not match model: %s", e.Table, strings.Join(e.Problems, "; "))
+}
+
+// Validate checks that table has all model columns with compatible types
+// and that there are no extra NOT NULL columns without defaults (inserts would fail).
+// Invalid relation declarations are reported as well.
+func (r *Repo) Validate(ctx context.Context) error {
+	if err := r.relationsErr(); err != nil {
+		return err
+	}
+
+	current, err := r.Introspect(ctx)
+	if err == ErrNotFound {
+		return &SchemaError{Table: r.table, Problems: []string{"table does not exist"}}
+	}
+	if err != nil {
+		return err
+	}
+
+	// pgx backend scans interval columns into durations as well as bigint milliseconds
+	var intervals []string
+	if _, ok := r.b.(*pgxBackend); ok {
+		for _, f := range parseProtoMsg(r.model) {
+			if f.val.Type().Implements(durationIfaceType) {
+				intervals = append(intervals, f.name)
+			}
+		}
+	}
+
+	return validateSchema(current, TableSchema(r.table, r.model, WithoutColumns(r.relationFields()...)), intervals)
+}
+
+// intervals are duration columns which can have interval type
+func validateSchema(current, target *Schema, intervals []string) error {
+	var problems []string
+
+	for _, c := range target.Columns {
+		cur := current.Column(c.Name)
+		if cur == nil {
+			problems = append(problems, fmt.Sprintf("missing column %s %s", c.Name, c.Type))
+			continue
+		}
+
+		if hasString(intervals, c.Name) && normalizeType(cur.Type) == "interval" {
+			continue
+		}
+		if !compatibleTypes(c.Type, cur.Type) {
+			problems = append(problems, fmt.Sprintf("column %s has type %s, model expects %s", c.Name, cur.Type, c.Type))
+		}
+	}
+
+	for _, c := range current.Columns {
+		if target.Column(c.Name) == nil && c.NotNull && !c.HasDefault {
+			problems = append(problems, fmt.Sprintf("extra column %s is NOT NULL without default", c.Name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &SchemaError{Table: target.Table, Problems: problems}
+	}
+
+	return nil
+}
+
+// column types which can store values of model type
+var compatibleColumnTypes = map[string][]string{
+	"integer": {"integer", "bigint", "numeric"},
+	"bigint":  {"bigint", "numeric"},
+	"real":    {"real", "double precision", "numeric"},
+	"text":    {"text", "character varying", "character", "citext"},
+	"jsonb":   {"jsonb", "json"},
+}
+
+func compatibleTypes(modelType, columnType string) bool {
+	modelType, columnType = normalizeType(modelType), normalizeType(columnType)
+
+	mArr, cArr := strings.HasSuffix(modelType, "[]"), strings.HasSuffix(columnType, "[]")
+	if mArr != cArr {
+		return false
+	}
+	if mArr {
+		return compatibleTypes(strings.TrimSuffix(modelType, "[]"), strings.TrimSuffix(columnType, "[]"))
+	}
+
+	// drop type modifiers: character varying(64), numeric(20,0)
+	if i := strings.Index(columnType, "("); i > 0 {
+		columnType = columnType[:i]
+	}
+
+	if modelType == columnType {
+		return true
+	}
+
+	for _, t := range compatibleColumnTypes[modelType] {
+		if t == columnType {
+			return true
+		}
+	}
+
+	return false
+}